@@ -0,0 +1,374 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultAnthropicEndpoint = "https://api.anthropic.com/v1/messages"
+	AnthropicVersion         = "2023-06-01"
+	defaultAnthropicMaxToken = 4096
+)
+
+type AnthropicProvider struct {
+	MaxTokens      int
+	ThinkingBudget int
+	CacheControl   bool
+}
+
+func NewAnthropicClient(endpoint, apiKey string, timeout time.Duration) *Client {
+	if strings.TrimSpace(endpoint) == "" {
+		endpoint = DefaultAnthropicEndpoint
+	}
+	c := NewClient(endpoint, apiKey, timeout)
+	c.Provider = &AnthropicProvider{CacheControl: true}
+	return c
+}
+
+type anthropicCacheControl struct {
+	Type string `json:"type"`
+}
+
+type anthropicBlock struct {
+	Type string `json:"type"`
+
+	Text string `json:"text,omitempty"`
+
+	Thinking  string `json:"thinking,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	Data      string `json:"data,omitempty"`
+
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+
+	ToolUseID string `json:"tool_use_id,omitempty"`
+	Content   string `json:"content,omitempty"`
+	IsError   bool   `json:"is_error,omitempty"`
+
+	Source *anthropicImageSource `json:"source,omitempty"`
+
+	CacheControl *anthropicCacheControl `json:"cache_control,omitempty"`
+}
+
+type anthropicImageSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+type anthropicMessage struct {
+	Role    string           `json:"role"`
+	Content []anthropicBlock `json:"content"`
+}
+
+type anthropicTool struct {
+	Name         string                 `json:"name"`
+	Description  string                 `json:"description,omitempty"`
+	InputSchema  map[string]interface{} `json:"input_schema"`
+	CacheControl *anthropicCacheControl `json:"cache_control,omitempty"`
+}
+
+type anthropicToolChoice struct {
+	Type                   string `json:"type"`
+	Name                   string `json:"name,omitempty"`
+	DisableParallelToolUse bool   `json:"disable_parallel_tool_use,omitempty"`
+}
+
+type anthropicThinking struct {
+	Type         string `json:"type"`
+	BudgetTokens int    `json:"budget_tokens"`
+}
+
+type anthropicRequest struct {
+	Model       string               `json:"model"`
+	MaxTokens   int                  `json:"max_tokens"`
+	System      []anthropicBlock     `json:"system,omitempty"`
+	Messages    []anthropicMessage   `json:"messages"`
+	Temperature *float64             `json:"temperature,omitempty"`
+	TopP        *float64             `json:"top_p,omitempty"`
+	Stop        []string             `json:"stop_sequences,omitempty"`
+	Tools       []anthropicTool      `json:"tools,omitempty"`
+	ToolChoice  *anthropicToolChoice `json:"tool_choice,omitempty"`
+	Thinking    *anthropicThinking   `json:"thinking,omitempty"`
+	Stream      bool                 `json:"stream,omitempty"`
+}
+
+type anthropicResponse struct {
+	ID         string           `json:"id"`
+	Type       string           `json:"type"`
+	Role       string           `json:"role"`
+	Model      string           `json:"model"`
+	Content    []anthropicBlock `json:"content"`
+	StopReason string           `json:"stop_reason"`
+	Usage      *struct {
+		InputTokens              int `json:"input_tokens"`
+		OutputTokens             int `json:"output_tokens"`
+		CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+		CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+	} `json:"usage"`
+	Error *apiErrorBody `json:"error,omitempty"`
+}
+
+func (p *AnthropicProvider) Name() string { return "anthropic" }
+
+func (p *AnthropicProvider) SetHeaders(h http.Header, apiKey string) {
+	h.Set("x-api-key", apiKey)
+	h.Set("anthropic-version", AnthropicVersion)
+	h.Set("Content-Type", "application/json")
+}
+
+func (p *AnthropicProvider) EncodeRequest(req ChatCompletionRequest) ([]byte, error) {
+	if req.Stream {
+		return nil, errors.New("anthropic provider does not support streaming")
+	}
+	out := anthropicRequest{
+		Model:       req.Model,
+		MaxTokens:   p.MaxTokens,
+		Temperature: req.Temperature,
+		TopP:        req.TopP,
+		Stop:        req.Stop,
+	}
+	if req.MaxTokens != nil {
+		out.MaxTokens = *req.MaxTokens
+	}
+	if out.MaxTokens <= 0 {
+		out.MaxTokens = defaultAnthropicMaxToken
+	}
+	if p.ThinkingBudget > 0 {
+		// Extended thinking only accepts the default temperature.
+		out.Temperature = nil
+		out.Thinking = &anthropicThinking{Type: "enabled", BudgetTokens: p.ThinkingBudget}
+		if out.MaxTokens <= p.ThinkingBudget {
+			out.MaxTokens = p.ThinkingBudget + defaultAnthropicMaxToken
+		}
+	}
+
+	for _, m := range req.Messages {
+		if m.Role == "system" {
+			if strings.TrimSpace(m.Content) != "" {
+				out.System = append(out.System, anthropicBlock{Type: "text", Text: m.Content})
+			}
+			continue
+		}
+		role, blocks, err := anthropicBlocks(m)
+		if err != nil {
+			return nil, err
+		}
+		if len(blocks) == 0 {
+			continue
+		}
+		if n := len(out.Messages); n > 0 && out.Messages[n-1].Role == role {
+			out.Messages[n-1].Content = append(out.Messages[n-1].Content, blocks...)
+			continue
+		}
+		out.Messages = append(out.Messages, anthropicMessage{Role: role, Content: blocks})
+	}
+
+	for _, t := range req.Tools {
+		schema := t.Function.Parameters
+		if schema == nil {
+			schema = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+		}
+		out.Tools = append(out.Tools, anthropicTool{
+			Name:        t.Function.Name,
+			Description: t.Function.Description,
+			InputSchema: schema,
+		})
+	}
+	if len(out.Tools) > 0 {
+		switch choice := req.ToolChoice; {
+		case choice != nil && choice.Function != "":
+			out.ToolChoice = &anthropicToolChoice{Type: "tool", Name: choice.Function}
+		case choice == nil || choice.Mode == "" || choice.Mode == "auto":
+			out.ToolChoice = &anthropicToolChoice{Type: "auto"}
+		case choice.Mode == "required":
+			out.ToolChoice = &anthropicToolChoice{Type: "any"}
+		case choice.Mode == "none":
+			out.ToolChoice = &anthropicToolChoice{Type: "none"}
+		default:
+			return nil, fmt.Errorf("unsupported tool_choice %q", choice.Mode)
+		}
+		if req.ParallelToolCalls != nil && !*req.ParallelToolCalls && out.ToolChoice.Type != "none" {
+			out.ToolChoice.DisableParallelToolUse = true
+		}
+	}
+
+	if p.CacheControl {
+		applyAnthropicCacheControl(&out)
+	}
+
+	b, err := json.Marshal(out)
+	if err != nil {
+		return nil, err
+	}
+	return mergeExtraFields(b, req.Extra)
+}
+
+// anthropicPartBlock maps an OpenAI content part to an Anthropic block;
+// data: URLs become base64 image sources, other URLs url sources.
+func anthropicPartBlock(p ContentPart) (anthropicBlock, error) {
+	switch {
+	case p.Type == "text":
+		return anthropicBlock{Type: "text", Text: p.Text}, nil
+	case p.Type == "image_url" && p.ImageURL != nil:
+		if strings.HasPrefix(p.ImageURL.URL, "data:") {
+			mimeType, _, ok := parseDataURL(p.ImageURL.URL)
+			if !ok {
+				return anthropicBlock{}, errors.New("image part has a malformed data: URL")
+			}
+			_, payload, _ := strings.Cut(p.ImageURL.URL, ",")
+			return anthropicBlock{Type: "image", Source: &anthropicImageSource{Type: "base64", MediaType: mimeType, Data: payload}}, nil
+		}
+		return anthropicBlock{Type: "image", Source: &anthropicImageSource{Type: "url", URL: p.ImageURL.URL}}, nil
+	}
+	return anthropicBlock{}, fmt.Errorf("unsupported content part %q", p.Type)
+}
+
+func anthropicBlocks(m Message) (string, []anthropicBlock, error) {
+	switch m.Role {
+	case "user":
+		var blocks []anthropicBlock
+		if m.Content != "" {
+			blocks = append(blocks, anthropicBlock{Type: "text", Text: m.Content})
+		}
+		for _, p := range m.Parts {
+			b, err := anthropicPartBlock(p)
+			if err != nil {
+				return "", nil, err
+			}
+			blocks = append(blocks, b)
+		}
+		return "user", blocks, nil
+	case "tool":
+		return "user", []anthropicBlock{{
+			Type:      "tool_result",
+			ToolUseID: m.ToolCallID,
+			Content:   m.Content,
+			IsError:   isToolErrorContent(m.Content),
+		}}, nil
+	case "assistant":
+		var blocks []anthropicBlock
+		for _, tb := range m.ThinkingBlocks {
+			blocks = append(blocks, anthropicBlock{Type: tb.Type, Thinking: tb.Thinking, Signature: tb.Signature, Data: tb.Data})
+		}
+		// Sessions saved before ThinkingBlocks existed carry a single signature.
+		if len(m.ThinkingBlocks) == 0 && m.ReasoningContent != "" && m.ThinkingSignature != "" {
+			blocks = append(blocks, anthropicBlock{Type: "thinking", Thinking: m.ReasoningContent, Signature: m.ThinkingSignature})
+		}
+		if m.Content != "" {
+			blocks = append(blocks, anthropicBlock{Type: "text", Text: m.Content})
+		}
+		for _, tc := range m.ToolCalls {
+			input := json.RawMessage(strings.TrimSpace(tc.Function.Arguments))
+			if len(input) == 0 {
+				input = json.RawMessage(`{}`)
+			}
+			if !json.Valid(input) {
+				return "", nil, fmt.Errorf("tool call %s has invalid JSON arguments", tc.ID)
+			}
+			blocks = append(blocks, anthropicBlock{Type: "tool_use", ID: tc.ID, Name: tc.Function.Name, Input: input})
+		}
+		return "assistant", blocks, nil
+	default:
+		return "", nil, fmt.Errorf("unsupported role %q", m.Role)
+	}
+}
+
+// applyAnthropicCacheControl places ephemeral breakpoints the way Claude Code
+// does: after the tool definitions, after the system prompt and on the last
+// block of the conversation so every turn reuses the previous prefix.
+func applyAnthropicCacheControl(req *anthropicRequest) {
+	ephemeral := &anthropicCacheControl{Type: "ephemeral"}
+	if n := len(req.Tools); n > 0 {
+		req.Tools[n-1].CacheControl = ephemeral
+	}
+	if n := len(req.System); n > 0 {
+		req.System[n-1].CacheControl = ephemeral
+	}
+	if n := len(req.Messages); n > 0 {
+		blocks := req.Messages[n-1].Content
+		if k := len(blocks); k > 0 && blocks[k-1].Type != "thinking" {
+			blocks[k-1].CacheControl = ephemeral
+		}
+	}
+}
+
+func (p *AnthropicProvider) DecodeResponse(body []byte, out *ChatCompletionResponse) error {
+	var resp anthropicResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return fmt.Errorf("decode json: %w", err)
+	}
+	if resp.Error != nil {
+		out.Error = resp.Error
+		return nil
+	}
+
+	msg := Message{Role: "assistant"}
+	var text, thinking []string
+	for _, b := range resp.Content {
+		switch b.Type {
+		case "text":
+			text = append(text, b.Text)
+		case "thinking":
+			thinking = append(thinking, b.Thinking)
+			msg.ThinkingBlocks = append(msg.ThinkingBlocks, ThinkingBlock{Type: b.Type, Thinking: b.Thinking, Signature: b.Signature})
+		case "redacted_thinking":
+			msg.ThinkingBlocks = append(msg.ThinkingBlocks, ThinkingBlock{Type: b.Type, Data: b.Data})
+		case "tool_use":
+			args := string(b.Input)
+			if strings.TrimSpace(args) == "" {
+				args = "{}"
+			}
+			msg.ToolCalls = append(msg.ToolCalls, ToolCall{
+				ID:       b.ID,
+				Type:     "function",
+				Function: ToolCallFunction{Name: b.Name, Arguments: args},
+			})
+		}
+	}
+	msg.Content = strings.Join(text, "")
+	msg.ReasoningContent = strings.Join(thinking, "\n")
+
+	*out = ChatCompletionResponse{
+		ID:      resp.ID,
+		Object:  "chat.completion",
+		Model:   resp.Model,
+		Choices: []Choice{{Message: msg, FinishReason: anthropicFinishReason(resp.StopReason)}},
+	}
+	if resp.Usage != nil {
+		prompt := resp.Usage.InputTokens + resp.Usage.CacheCreationInputTokens + resp.Usage.CacheReadInputTokens
+		out.Usage = &Usage{
+			PromptTokens:     prompt,
+			CompletionTokens: resp.Usage.OutputTokens,
+			TotalTokens:      prompt + resp.Usage.OutputTokens,
+		}
+		if resp.Usage.CacheReadInputTokens > 0 || resp.Usage.CacheCreationInputTokens > 0 {
+			out.Usage.PromptTokensDetails = &PromptTokensDetails{
+				CachedTokens:        resp.Usage.CacheReadInputTokens,
+				CacheCreationTokens: resp.Usage.CacheCreationInputTokens,
+			}
+		}
+	}
+	return nil
+}
+
+func anthropicFinishReason(stop string) string {
+	switch stop {
+	case "end_turn", "stop_sequence":
+		return "stop"
+	case "tool_use":
+		return "tool_calls"
+	case "max_tokens":
+		return "length"
+	case "refusal":
+		return "content_filter"
+	}
+	return stop
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAnthropicProvider_EncodeRequest(t *testing.T) {
+	calcTool, _ := CalculatorTool()
+	p := &AnthropicProvider{CacheControl: true}
+	payload, err := p.EncodeRequest(ChatCompletionRequest{
+		Model: "claude-sonnet-4-5",
+		Messages: []Message{
+			{Role: "system", Content: "be brief"},
+			{Role: "user", Content: "1+2?"},
+			{Role: "assistant", ToolCalls: []ToolCall{{ID: "toolu_1", Type: "function", Function: ToolCallFunction{Name: "calculator", Arguments: `{"expression":"1+2"}`}}}},
+			{Role: "tool", ToolCallID: "toolu_1", Content: "3"},
+		},
+		Tools:      []Tool{calcTool},
+		ToolChoice: ToolChoiceRequired(),
+	})
+	if err != nil {
+		t.Fatalf("EncodeRequest error: %v", err)
+	}
+
+	var got struct {
+		MaxTokens int `json:"max_tokens"`
+		System    []struct {
+			Text         string          `json:"text"`
+			CacheControl json.RawMessage `json:"cache_control"`
+		} `json:"system"`
+		Messages []struct {
+			Role    string `json:"role"`
+			Content []struct {
+				Type         string          `json:"type"`
+				ID           string          `json:"id"`
+				Input        json.RawMessage `json:"input"`
+				ToolUseID    string          `json:"tool_use_id"`
+				Content      string          `json:"content"`
+				CacheControl json.RawMessage `json:"cache_control"`
+			} `json:"content"`
+		} `json:"messages"`
+		Tools []struct {
+			Name        string         `json:"name"`
+			InputSchema map[string]any `json:"input_schema"`
+		} `json:"tools"`
+		ToolChoice struct {
+			Type string `json:"type"`
+		} `json:"tool_choice"`
+	}
+	if err := json.Unmarshal(payload, &got); err != nil {
+		t.Fatalf("decode payload: %v\n%s", err, payload)
+	}
+
+	if got.MaxTokens != defaultAnthropicMaxToken {
+		t.Fatalf("max_tokens = %d", got.MaxTokens)
+	}
+	if len(got.System) != 1 || got.System[0].Text != "be brief" || len(got.System[0].CacheControl) == 0 {
+		t.Fatalf("system = %+v", got.System)
+	}
+	if len(got.Messages) != 3 {
+		t.Fatalf("messages = %s", payload)
+	}
+	if use := got.Messages[1].Content[0]; use.Type != "tool_use" || use.ID != "toolu_1" || string(use.Input) != `{"expression":"1+2"}` {
+		t.Fatalf("tool_use = %+v", use)
+	}
+	last := got.Messages[2]
+	if last.Role != "user" || last.Content[0].Type != "tool_result" || last.Content[0].ToolUseID != "toolu_1" || last.Content[0].Content != "3" {
+		t.Fatalf("tool_result = %+v", last)
+	}
+	if len(last.Content[0].CacheControl) == 0 {
+		t.Fatalf("expected cache_control on last block: %s", payload)
+	}
+	if len(got.Tools) != 1 || got.Tools[0].Name != "calculator" || got.Tools[0].InputSchema["type"] != "object" {
+		t.Fatalf("tools = %+v", got.Tools)
+	}
+	if got.ToolChoice.Type != "any" {
+		t.Fatalf("tool_choice = %+v", got.ToolChoice)
+	}
+}
+
+func TestAnthropicProvider_DecodeResponse(t *testing.T) {
+	body := `{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-5",
+		"content":[{"type":"thinking","thinking":"use the tool","signature":"sig"},{"type":"text","text":"Let me compute."},{"type":"tool_use","id":"toolu_1","name":"calculator","input":{"expression":"1+2"}}],
+		"stop_reason":"tool_use","usage":{"input_tokens":10,"output_tokens":5,"cache_read_input_tokens":100}}`
+
+	var out ChatCompletionResponse
+	if err := (&AnthropicProvider{}).DecodeResponse([]byte(body), &out); err != nil {
+		t.Fatalf("DecodeResponse error: %v", err)
+	}
+	choice := out.Choices[0]
+	if choice.FinishReason != "tool_calls" {
+		t.Fatalf("finish = %q", choice.FinishReason)
+	}
+	msg := choice.Message
+	if msg.Content != "Let me compute." || msg.ReasoningContent != "use the tool" || len(msg.ThinkingBlocks) != 1 || msg.ThinkingBlocks[0].Signature != "sig" {
+		t.Fatalf("message = %+v", msg)
+	}
+	if len(msg.ToolCalls) != 1 || msg.ToolCalls[0].Function.Arguments != `{"expression":"1+2"}` {
+		t.Fatalf("tool calls = %+v", msg.ToolCalls)
+	}
+	if out.Usage == nil || out.Usage.PromptTokens != 110 || out.Usage.TotalTokens != 115 || out.Usage.CachedTokens() != 100 {
+		t.Fatalf("usage = %+v", out.Usage)
+	}
+}
+
+func TestDoReACT_AnthropicProvider(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-api-key") != "test-key" || r.Header.Get("anthropic-version") != AnthropicVersion {
+			http.Error(w, `{"type":"error","error":{"type":"authentication_error","message":"bad headers"}}`, http.StatusUnauthorized)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if calls.Add(1) == 1 {
+			io.WriteString(w, `{"id":"msg_1","role":"assistant","content":[{"type":"tool_use","id":"toolu_1","name":"calculator","input":{"expression":"(1+2)*3"}}],"stop_reason":"tool_use"}`)
+			return
+		}
+		if !strings.Contains(string(body), `"tool_result"`) {
+			http.Error(w, `{"type":"error","error":{"type":"invalid_request_error","message":"missing tool_result"}}`, http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, `{"id":"msg_2","role":"assistant","content":[{"type":"text","text":"9"}],"stop_reason":"end_turn"}`)
+	}))
+	defer srv.Close()
+
+	client := NewAnthropicClient(srv.URL, "test-key", 5*time.Second)
+	tools, handlers := BuiltinTools()
+	res, err := doReACT(context.Background(), client, "claude-sonnet-4-5", "sys", "(1+2)*3?", tools, handlers, 0, 4)
+	if err != nil {
+		t.Fatalf("doReACT error: %v", err)
+	}
+	if res.Final != "9" {
+		t.Fatalf("final = %q", res.Final)
+	}
+	if tool := res.Messages[3]; tool.Role != "tool" || tool.Content != "9" {
+		t.Fatalf("tool message = %+v", tool)
+	}
+}
+
+func TestAnthropicProvider_ThinkingReplayAndToolErrors(t *testing.T) {
+	p := &AnthropicProvider{ThinkingBudget: 2048}
+	body := `{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4-5",
+		"content":[{"type":"thinking","thinking":"first","signature":"sig1"},{"type":"redacted_thinking","data":"opaque"},{"type":"thinking","thinking":"second","signature":"sig2"},
+			{"type":"tool_use","id":"toolu_1","name":"calculator","input":{"expression":"1/0"}}],
+		"stop_reason":"tool_use"}`
+	var resp ChatCompletionResponse
+	if err := p.DecodeResponse([]byte(body), &resp); err != nil {
+		t.Fatal(err)
+	}
+	assistant := resp.Choices[0].Message
+	if len(assistant.ThinkingBlocks) != 3 || assistant.ReasoningContent != "first\nsecond" {
+		t.Fatalf("assistant = %+v", assistant)
+	}
+
+	payload, err := p.EncodeRequest(ChatCompletionRequest{
+		Model:       "claude-sonnet-4-5",
+		Temperature: Ptr(0.2),
+		Messages: []Message{
+			{Role: "user", Content: "divide"},
+			assistant,
+			{Role: "tool", ToolCallID: "toolu_1", Content: "tool error: division by zero"},
+			{Role: "user", Content: "ok"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(payload)
+	for _, want := range []string{
+		`{"type":"thinking","thinking":"first","signature":"sig1"},{"type":"redacted_thinking","data":"opaque"},{"type":"thinking","thinking":"second","signature":"sig2"}`,
+		`"content":"tool error: division by zero","is_error":true`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("payload missing %s:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, `"temperature"`) {
+		t.Fatalf("temperature sent with thinking enabled:\n%s", out)
+	}
+}
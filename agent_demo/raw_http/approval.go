@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+type ApprovalAction int
+
+const (
+	ApprovalAllow ApprovalAction = iota
+	ApprovalDeny
+)
+
+// ToolApproval is the verdict on one tool call. A non-empty Arguments on an
+// allowed call replaces the model's arguments before the handler runs.
+type ToolApproval struct {
+	Action    ApprovalAction
+	Reason    string
+	Arguments string
+}
+
+func Approve() ToolApproval { return ToolApproval{Action: ApprovalAllow} }
+
+func ApproveWithArgs(args string) ToolApproval {
+	return ToolApproval{Action: ApprovalAllow, Arguments: args}
+}
+
+func Deny(reason string) ToolApproval { return ToolApproval{Action: ApprovalDeny, Reason: reason} }
+
+type ToolApprover interface {
+	ApproveToolCall(ctx context.Context, tc ToolCall) (ToolApproval, error)
+}
+
+type ToolApproverFunc func(ctx context.Context, tc ToolCall) (ToolApproval, error)
+
+func (f ToolApproverFunc) ApproveToolCall(ctx context.Context, tc ToolCall) (ToolApproval, error) {
+	return f(ctx, tc)
+}
+
+// WithApprover gates every tool call on a. Calls of one step are approved
+// one at a time in call order, before any of them runs, so an interactive
+// approver never sees overlapping prompts. Denied calls are answered with a
+// tool message carrying the reason.
+func WithApprover(a ToolApprover) ReACTOption {
+	return func(c *reactConfig) { c.approver = a }
+}
+
+// approveToolCalls asks the approver about each call, rewriting arguments in
+// place when the approver modifies them, so calls must not share its backing
+// array with a recorded response. It returns the denial message for each
+// denied call (empty for approved ones).
+func approveToolCalls(ctx context.Context, cfg *reactConfig, step int, calls []ToolCall) []string {
+	denied := make([]string, len(calls))
+	if cfg.approver == nil {
+		return denied
+	}
+	for i := range calls {
+		verdict, err := cfg.approver.ApproveToolCall(ctx, calls[i])
+		if err != nil {
+			verdict = Deny(fmt.Sprintf("approval failed: %v", err))
+		}
+		if verdict.Action == ApprovalAllow {
+			if verdict.Arguments != "" {
+				calls[i].Function.Arguments = verdict.Arguments
+			}
+			continue
+		}
+		reason := strings.TrimSpace(verdict.Reason)
+		if reason == "" {
+			reason = "denied by user"
+		}
+		denied[i] = fmt.Sprintf("tool call denied: %s", reason)
+		cfg.emit(ReACTEvent{Type: EventToolDenied, Step: step, ToolCall: &calls[i], Result: denied[i]})
+	}
+	return denied
+}
+
+// ToolPermissions is a rule-based approver in the style of allowed-tools
+// front matter. Rules are tool names, optionally ending in "*" for a prefix
+// match ("mcp__doris__*"), or carry an argument pattern in parentheses; a
+// trailing ":*" or "*" in the pattern is a prefix match.
+//
+// "run_sql(sql=SELECT:*)" checks the named top-level string argument. The
+// short form "run_sql(SELECT:*)" is read differently by the two lists: an
+// Allow rule only matches a call whose sole argument is a matching string,
+// so an extra harmless argument cannot smuggle another one past it, while a
+// Deny rule matches when any top-level string argument matches.
+// Deny rules win over Allow rules; calls matching neither go to Ask, or are
+// denied when Ask is nil.
+type ToolPermissions struct {
+	Allow []string
+	Deny  []string
+	Ask   ToolApprover
+}
+
+func (p *ToolPermissions) ApproveToolCall(ctx context.Context, tc ToolCall) (ToolApproval, error) {
+	if rule, ok := matchPermissionRules(p.Deny, tc, false); ok {
+		return Deny(fmt.Sprintf("%s is blocked by rule %q", tc.Function.Name, rule)), nil
+	}
+	if _, ok := matchPermissionRules(p.Allow, tc, true); ok {
+		return Approve(), nil
+	}
+	if p.Ask != nil {
+		verdict, err := p.Ask.ApproveToolCall(ctx, tc)
+		if err != nil || verdict.Action != ApprovalAllow || verdict.Arguments == "" {
+			return verdict, err
+		}
+		// Edited arguments must not slip past the Deny rules.
+		edited := tc
+		edited.Function.Arguments = verdict.Arguments
+		if rule, ok := matchPermissionRules(p.Deny, edited, false); ok {
+			return Deny(fmt.Sprintf("edited arguments for %s are blocked by rule %q", tc.Function.Name, rule)), nil
+		}
+		return verdict, nil
+	}
+	return Deny(fmt.Sprintf("%s is not in the allowed tools", tc.Function.Name)), nil
+}
+
+// ParseToolRules splits a comma-separated rule list, keeping commas inside
+// parentheses: "now, run_sql(SELECT:*)" -> ["now", "run_sql(SELECT:*)"].
+func ParseToolRules(spec string) []string {
+	var out []string
+	depth, start := 0, 0
+	flush := func(end int) {
+		if r := strings.TrimSpace(spec[start:end]); r != "" {
+			out = append(out, r)
+		}
+	}
+	for i, r := range spec {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			if depth > 0 {
+				depth--
+			}
+		case ',':
+			if depth == 0 {
+				flush(i)
+				start = i + 1
+			}
+		}
+	}
+	flush(len(spec))
+	return out
+}
+
+func matchPermissionRules(rules []string, tc ToolCall, strict bool) (string, bool) {
+	for _, rule := range rules {
+		if matchPermissionRule(rule, tc, strict) {
+			return rule, true
+		}
+	}
+	return "", false
+}
+
+// matchPermissionRule reports whether rule covers tc. strict selects the
+// Allow reading of the short "name(pattern)" form, see ToolPermissions.
+func matchPermissionRule(rule string, tc ToolCall, strict bool) bool {
+	name, argPattern, hasArgs := strings.Cut(rule, "(")
+	if !matchToolPatterns([]string{strings.TrimSpace(name)}, tc.Function.Name) {
+		return false
+	}
+	if !hasArgs {
+		return true
+	}
+	argPattern = strings.TrimSuffix(argPattern, ")")
+	argName, pattern, named := strings.Cut(argPattern, "=")
+	if !named {
+		pattern = argPattern
+	}
+
+	var args map[string]interface{}
+	if json.Unmarshal([]byte(tc.Function.Arguments), &args) != nil {
+		return false
+	}
+	if named {
+		s, ok := args[strings.TrimSpace(argName)].(string)
+		return ok && matchArgPattern(pattern, s)
+	}
+	if strict {
+		if len(args) != 1 {
+			return false
+		}
+		for _, v := range args {
+			s, ok := v.(string)
+			return ok && matchArgPattern(pattern, s)
+		}
+	}
+	for _, v := range args {
+		if s, ok := v.(string); ok && matchArgPattern(pattern, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchArgPattern(pattern, s string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(s, strings.TrimSuffix(prefix, ":"))
+	}
+	return s == pattern
+}
+
+// TerminalApprover asks on the terminal before each tool call:
+// y approves, n denies (with an optional reason), a approves this tool for
+// the rest of the run, e replaces the arguments with a line of JSON.
+type TerminalApprover struct {
+	mu     sync.Mutex
+	in     *bufio.Reader
+	out    io.Writer
+	always map[string]bool
+}
+
+func NewTerminalApprover(in io.Reader, out io.Writer) *TerminalApprover {
+	return &TerminalApprover{in: bufio.NewReader(in), out: out, always: map[string]bool{}}
+}
+
+func (a *TerminalApprover) ApproveToolCall(ctx context.Context, tc ToolCall) (ToolApproval, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.always[tc.Function.Name] {
+		return Approve(), nil
+	}
+
+	fmt.Fprintf(a.out, "\nTool call %s (id=%s)\n", tc.Function.Name, tc.ID)
+	if args := prettyMaybeJSON(tc.Function.Arguments); args != "" {
+		fmt.Fprintln(a.out, indentBlock(args, "  "))
+	}
+	for {
+		fmt.Fprint(a.out, "Allow? [y]es / [n]o / [a]lways / [e]dit arguments: ")
+		answer, err := a.readLine()
+		if err != nil {
+			return Deny("no approval input"), err
+		}
+		switch strings.ToLower(answer) {
+		case "y", "yes":
+			return Approve(), nil
+		case "a", "always":
+			a.always[tc.Function.Name] = true
+			return Approve(), nil
+		case "n", "no":
+			fmt.Fprint(a.out, "Reason (optional): ")
+			reason, _ := a.readLine()
+			if reason == "" {
+				reason = "denied by user"
+			}
+			return Deny(reason), nil
+		case "e", "edit":
+			fmt.Fprint(a.out, "New arguments (JSON): ")
+			args, err := a.readLine()
+			if err != nil {
+				return Deny("no approval input"), err
+			}
+			if !json.Valid([]byte(args)) {
+				fmt.Fprintln(a.out, "not valid JSON")
+				continue
+			}
+			return ApproveWithArgs(args), nil
+		}
+	}
+}
+
+func (a *TerminalApprover) readLine() (string, error) {
+	line, err := a.in.ReadString('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseToolRules(t *testing.T) {
+	got := ParseToolRules(" now, run_sql(SELECT:*), mcp__doris__* ,, Bash(gh pr view:*)")
+	want := []string{"now", "run_sql(SELECT:*)", "mcp__doris__*", "Bash(gh pr view:*)"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("rules = %q", got)
+	}
+}
+
+func TestToolPermissions(t *testing.T) {
+	asked := 0
+	perms := &ToolPermissions{
+		Allow: []string{"now", "mcp__doris__get_*", "run_sql(SELECT:*)"},
+		Deny:  []string{"mcp__doris__get_secret", "run_sql(DROP:*)"},
+		Ask: ToolApproverFunc(func(ctx context.Context, tc ToolCall) (ToolApproval, error) {
+			asked++
+			return Deny("asked"), nil
+		}),
+	}
+	cases := []struct {
+		call   ToolCall
+		action ApprovalAction
+		reason string
+	}{
+		{toolCall("1", "now", `{}`), ApprovalAllow, ""},
+		{toolCall("2", "mcp__doris__get_db_table_list", `{}`), ApprovalAllow, ""},
+		{toolCall("3", "mcp__doris__get_secret", `{}`), ApprovalDeny, `blocked by rule "mcp__doris__get_secret"`},
+		{toolCall("4", "run_sql", `{"sql":"SELECT 1"}`), ApprovalAllow, ""},
+		{toolCall("5", "run_sql", `{"sql":"DROP TABLE t"}`), ApprovalDeny, "blocked by rule"},
+		{toolCall("6", "run_sql", `{"sql":"UPDATE t SET x=1"}`), ApprovalDeny, "asked"},
+	}
+	for _, c := range cases {
+		v, err := perms.ApproveToolCall(context.Background(), c.call)
+		if err != nil || v.Action != c.action || !strings.Contains(v.Reason, c.reason) {
+			t.Fatalf("%s %s: verdict = %+v, err = %v", c.call.Function.Name, c.call.Function.Arguments, v, err)
+		}
+	}
+	if asked != 1 {
+		t.Fatalf("asked = %d", asked)
+	}
+
+	strict := &ToolPermissions{Allow: []string{"now"}}
+	if v, _ := strict.ApproveToolCall(context.Background(), toolCall("1", "calculator", `{}`)); v.Action != ApprovalDeny || !strings.Contains(v.Reason, "not in the allowed tools") {
+		t.Fatalf("strict verdict = %+v", v)
+	}
+}
+
+func TestToolPermissions_ArgumentRules(t *testing.T) {
+	perms := &ToolPermissions{
+		Allow: []string{"run_sql(SELECT:*)", "query(sql=SELECT:*)"},
+		Deny:  []string{"run_sql(DROP:*)"},
+	}
+	cases := []struct {
+		call   ToolCall
+		action ApprovalAction
+		reason string
+	}{
+		// A harmless second argument must not approve the dangerous one.
+		{toolCall("1", "run_sql", `{"query":"DELETE FROM users","note":"SELECT"}`), ApprovalDeny, "not in the allowed tools"},
+		{toolCall("2", "query", `{"sql":"DELETE FROM users","note":"SELECT"}`), ApprovalDeny, "not in the allowed tools"},
+		{toolCall("3", "query", `{"sql":"SELECT 1","limit":"10"}`), ApprovalAllow, ""},
+		{toolCall("4", "query", `{"note":"SELECT"}`), ApprovalDeny, "not in the allowed tools"},
+		// Deny rules still look at every string argument.
+		{toolCall("5", "run_sql", `{"note":"x","query":"DROP TABLE users"}`), ApprovalDeny, "blocked by rule"},
+	}
+	for _, c := range cases {
+		v, err := perms.ApproveToolCall(context.Background(), c.call)
+		if err != nil || v.Action != c.action || !strings.Contains(v.Reason, c.reason) {
+			t.Fatalf("%s %s: verdict = %+v, err = %v", c.call.Function.Name, c.call.Function.Arguments, v, err)
+		}
+	}
+}
+
+func TestTerminalApprover(t *testing.T) {
+	in := strings.NewReader("maybe\nn\ntoo risky\ne\nnot json\ne\n{\"expression\":\"2*2\"}\na\n")
+	var out bytes.Buffer
+	a := NewTerminalApprover(in, &out)
+	ctx := context.Background()
+	calc := toolCall("call_1", "calculator", `{"expression":"1+1"}`)
+
+	if v, _ := a.ApproveToolCall(ctx, calc); v.Action != ApprovalDeny || v.Reason != "too risky" {
+		t.Fatalf("first = %+v", v)
+	}
+	if v, _ := a.ApproveToolCall(ctx, calc); v.Action != ApprovalAllow || v.Arguments != `{"expression":"2*2"}` {
+		t.Fatalf("edit = %+v", v)
+	}
+	if v, _ := a.ApproveToolCall(ctx, toolCall("call_2", "now", `{}`)); v.Action != ApprovalAllow {
+		t.Fatalf("always = %+v", v)
+	}
+	if v, err := a.ApproveToolCall(ctx, toolCall("call_3", "now", `{}`)); err != nil || v.Action != ApprovalAllow {
+		t.Fatalf("remembered = %+v, %v", v, err)
+	}
+	if v, err := a.ApproveToolCall(ctx, calc); err == nil || v.Action != ApprovalDeny {
+		t.Fatalf("eof = %+v, %v", v, err)
+	}
+	if !strings.Contains(out.String(), "Tool call calculator (id=call_1)") || !strings.Contains(out.String(), "not valid JSON") {
+		t.Fatalf("prompt output:\n%s", out.String())
+	}
+}
+
+func TestDoReACT_Offline_ApprovalGate(t *testing.T) {
+	srv := newFakeServer(t,
+		fakeToolCalls(
+			toolCall("call_1", "drop_table", `{"table":"orders"}`),
+			toolCall("call_2", "calculator", `{"expression":"1+1"}`),
+		),
+		fakeText("done"),
+	)
+	tools, handlers := BuiltinTools()
+	dropped := false
+	tools = append(tools, Tool{Type: "function", Function: ToolFunction{Name: "drop_table"}})
+	handlers["drop_table"] = func(ctx context.Context, args json.RawMessage) (string, error) {
+		dropped = true
+		return "dropped", nil
+	}
+
+	var order []string
+	approver := ToolApproverFunc(func(ctx context.Context, tc ToolCall) (ToolApproval, error) {
+		order = append(order, tc.ID)
+		if tc.Function.Name == "drop_table" {
+			return Deny("production table"), nil
+		}
+		return ApproveWithArgs(`{"expression":"20+22"}`), nil
+	})
+	rec := &eventRecorder{}
+	res, err := doReACT(context.Background(), srv.APIClient(), "qwen-plus", "sys", "go", tools, handlers, 0, 4, WithApprover(approver), WithObserver(rec))
+	if err != nil {
+		t.Fatalf("doReACT error: %v", err)
+	}
+	if dropped {
+		t.Fatalf("denied handler ran")
+	}
+	if strings.Join(order, ",") != "call_1,call_2" {
+		t.Fatalf("approval order = %v", order)
+	}
+
+	sent := srv.Requests()[1].Req.Messages
+	if got := sent[3].Content; got != "tool call denied: production table" {
+		t.Fatalf("denied message = %q", got)
+	}
+	if got := sent[4].Content; got != "42" {
+		t.Fatalf("modified call result = %q", got)
+	}
+	if got := res.Messages[res.BaseMessagesLen].ToolCalls[1].Function.Arguments; got != `{"expression":"20+22"}` {
+		t.Fatalf("history arguments = %q", got)
+	}
+	if got := res.Invokes[0].Response.Choices[0].Message.ToolCalls[1].Function.Arguments; got != `{"expression":"1+1"}` {
+		t.Fatalf("recorded response arguments = %q", got)
+	}
+	if !strings.Contains(rec.types(), "tool_denied") {
+		t.Fatalf("events = %s", rec.types())
+	}
+}
+
+func TestToolPermissions_EditedArgumentsRecheckDeny(t *testing.T) {
+	perms := &ToolPermissions{
+		Deny: []string{"run_sql(sql=DROP:*)"},
+		Ask: ToolApproverFunc(func(ctx context.Context, tc ToolCall) (ToolApproval, error) {
+			return ApproveWithArgs(`{"sql":"DROP TABLE users"}`), nil
+		}),
+	}
+	v, err := perms.ApproveToolCall(context.Background(), toolCall("1", "run_sql", `{"sql":"SELECT 1"}`))
+	if err != nil || v.Action != ApprovalDeny || !strings.Contains(v.Reason, "edited arguments") {
+		t.Fatalf("verdict = %+v, err = %v", v, err)
+	}
+}
+
+func TestExecuteToolCalls_ApprovalOutsideStepTimeout(t *testing.T) {
+	cfg := newReACTConfig([]ReACTOption{
+		WithStepTimeout(50 * time.Millisecond),
+		WithApprover(ToolApproverFunc(func(ctx context.Context, tc ToolCall) (ToolApproval, error) {
+			time.Sleep(100 * time.Millisecond) // a slow human at the prompt
+			return Approve(), nil
+		})),
+	})
+	handlers := map[string]ToolHandler{"ok": func(ctx context.Context, args json.RawMessage) (string, error) {
+		return "fine", ctx.Err()
+	}}
+	results, _ := executeToolCalls(context.Background(), cfg, 1, []ToolCall{toolCall("a", "ok", `{}`)}, handlers)
+	if results[0].Content != "fine" {
+		t.Fatalf("result = %q", results[0].Content)
+	}
+}
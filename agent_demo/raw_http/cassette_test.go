@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCassette_RecordThenReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "react.json")
+	srv := newFakeServer(t,
+		fakeToolCalls(toolCall("call_1", "calculator", `{"expression":"(1+2)*3"}`)),
+		fakeText("9"),
+	)
+	tools, handlers := BuiltinTools()
+
+	recClient := srv.APIClient()
+	if err := recClient.UseCassette(CassetteRecord, path); err != nil {
+		t.Fatalf("UseCassette(record) error: %v", err)
+	}
+	recorded, err := doReACT(context.Background(), recClient, "qwen-plus", "sys", "(1+2)*3?", tools, handlers, 0, 4)
+	if err != nil {
+		t.Fatalf("record run error: %v", err)
+	}
+	srv.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read cassette: %v", err)
+	}
+	if strings.Contains(string(data), "test-key") {
+		t.Fatalf("cassette leaked the API key:\n%s", data)
+	}
+
+	repClient := NewClient(srv.URL, "other-key", 0)
+	if err := repClient.UseCassette(CassetteReplay, path); err != nil {
+		t.Fatalf("UseCassette(replay) error: %v", err)
+	}
+	replayed, err := doReACT(context.Background(), repClient, "qwen-plus", "sys", "(1+2)*3?", tools, handlers, 0, 4)
+	if err != nil {
+		t.Fatalf("replay run error: %v", err)
+	}
+	if replayed.Final != recorded.Final || len(replayed.Messages) != len(recorded.Messages) {
+		t.Fatalf("replay = %+v, want %+v", replayed, recorded)
+	}
+	for i := range recorded.Invokes {
+		if !bytes.Equal(replayed.Invokes[i].RawResponse, recorded.Invokes[i].RawResponse) {
+			t.Fatalf("invoke %d raw response differs", i)
+		}
+	}
+
+	if _, err := repClient.Invoke(context.Background(), ChatCompletionRequest{Model: "qwen-plus"}); !errors.Is(err, ErrCassetteMiss) {
+		t.Fatalf("err = %v, want ErrCassetteMiss", err)
+	}
+}
+
+func TestNormalizeCassetteBody(t *testing.T) {
+	a := normalizeCassetteBody([]byte(`{"b":1, "a":{"y":2,"x":1}}`))
+	b := normalizeCassetteBody([]byte("{\n  \"a\": {\"x\": 1, \"y\": 2},\n  \"b\": 1\n}"))
+	if !bytes.Equal(a, b) {
+		t.Fatalf("normalized bodies differ: %s vs %s", a, b)
+	}
+}
+
+func TestRun_ReplayWithoutAPIKey(t *testing.T) {
+	t.Setenv("QWEN_API_KEY", "")
+	path := filepath.Join(t.TempDir(), "chat.json")
+
+	srv := newFakeServer(t, fakeText("你好！"))
+	client := srv.APIClient()
+	if err := client.UseCassette(CassetteRecord, path); err != nil {
+		t.Fatalf("UseCassette error: %v", err)
+	}
+	sess := NewSessionWithClient(client, "qwen-plus")
+	sess.SetSystemPrompt("You are a helpful assistant.")
+	if _, err := sess.Chat(context.Background(), "hi"); err != nil {
+		t.Fatalf("record chat error: %v", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-prompt", "hi", "-endpoint", srv.URL, "-replay", path}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code = %d, stderr = %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "你好！") {
+		t.Fatalf("stdout = %s", stdout.String())
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type CompactStrategy int
+
+const (
+	CompactDrop CompactStrategy = iota
+	CompactSummarize
+)
+
+const DefaultSummaryPrompt = `你是对话压缩助手。请把下面的历史对话总结成一段简洁的摘要，
+保留用户的目标、已确认的事实、工具调用得到的关键结果以及尚未完成的事项，不要编造内容。`
+
+type ContextPolicy struct {
+	MaxPromptTokens int             `json:"max_prompt_tokens"`
+	Strategy        CompactStrategy `json:"strategy"`
+	KeepRecentTurns int             `json:"keep_recent_turns"`
+	SummaryPrompt   string          `json:"summary_prompt,omitempty"`
+}
+
+type Compaction struct {
+	Strategy        CompactStrategy
+	TokensBefore    int
+	TokensAfter     int
+	RemovedMessages int
+	Summary         string
+	Invoke          *InvokeResult
+	Err             error
+}
+
+func (s *Session) SetContextPolicy(p ContextPolicy) { s.contextPolicy = p }
+
+func (s *Session) LastCompaction() *Compaction {
+	if s == nil {
+		return nil
+	}
+	return s.lastCompaction
+}
+
+// estimatedImageTokens is a flat per-image cost; vision models bill by
+// resolution, which is not worth decoding here.
+const estimatedImageTokens = 1000
+
+func estimateTokens(msgs []Message, tools []Tool) int {
+	n := 0
+	for _, m := range msgs {
+		n += 4 + estimateTextTokens(m.Content) + estimateTextTokens(m.ReasoningContent)
+		for _, p := range m.Parts {
+			if p.Type == "image_url" {
+				n += estimatedImageTokens
+			} else {
+				n += estimateTextTokens(p.Text)
+			}
+		}
+		for _, tc := range m.ToolCalls {
+			n += 4 + estimateTextTokens(tc.Function.Name) + estimateTextTokens(tc.Function.Arguments)
+		}
+	}
+	if len(tools) > 0 {
+		if b, err := json.Marshal(tools); err == nil {
+			n += estimateTextTokens(string(b))
+		}
+	}
+	return n
+}
+
+// estimateTextTokens is a provider-agnostic approximation: CJK characters
+// tend to be one token each, everything else roughly four bytes per token.
+func estimateTextTokens(s string) int {
+	cjk, other := 0, 0
+	for _, r := range s {
+		if unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r) {
+			cjk++
+		} else {
+			other++
+		}
+	}
+	return cjk + (other+3)/4
+}
+
+func (s *Session) historyStart() int {
+	if len(s.messages) > 0 && s.messages[0].Role == "system" {
+		return 1
+	}
+	return 0
+}
+
+func (s *Session) turnStarts(limit int) []int {
+	var out []int
+	for i := s.historyStart(); i < limit; i++ {
+		if s.messages[i].Role == "user" {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+// compact shrinks the history before index limit (the pending user message)
+// according to the context policy and returns how many messages were removed.
+func (s *Session) compact(ctx context.Context, limit int) int {
+	p := s.contextPolicy
+	if p.MaxPromptTokens <= 0 {
+		return 0
+	}
+	before := estimateTokens(s.messages, s.tools)
+	if before <= p.MaxPromptTokens {
+		return 0
+	}
+
+	starts := s.turnStarts(limit)
+	keep := p.KeepRecentTurns
+	if keep < 0 {
+		keep = 0
+	}
+	if len(starts) <= keep {
+		return 0
+	}
+	cut := limit
+	if keep > 0 {
+		cut = starts[len(starts)-keep]
+	}
+	start := s.historyStart()
+	if cut <= start {
+		return 0
+	}
+
+	c := &Compaction{Strategy: p.Strategy, TokensBefore: before}
+	removed := 0
+	if p.Strategy == CompactSummarize {
+		removed, c.Err = s.summarizeRange(ctx, start, cut, c)
+	}
+	if p.Strategy == CompactDrop || c.Err != nil {
+		for estimateTokens(s.messages, s.tools) > p.MaxPromptTokens {
+			dropped := s.dropOldestTurn(cut - removed)
+			if dropped == 0 {
+				break
+			}
+			removed += dropped
+		}
+	}
+	c.RemovedMessages = removed
+	c.TokensAfter = estimateTokens(s.messages, s.tools)
+	s.lastCompaction = c
+	return removed
+}
+
+func (s *Session) summarizeRange(ctx context.Context, start, end int, c *Compaction) (int, error) {
+	prompt := strings.TrimSpace(s.contextPolicy.SummaryPrompt)
+	if prompt == "" {
+		prompt = DefaultSummaryPrompt
+	}
+	transcript := RenderReACTResult(&ReACTResult{Messages: s.messages[start:end]})
+
+	invoke, err := s.client.Invoke(ctx, ChatCompletionRequest{
+		Model: s.model,
+		Messages: []Message{
+			{Role: "system", Content: prompt},
+			{Role: "user", Content: transcript},
+		},
+		Temperature: Ptr(0.0),
+	})
+	c.Invoke = invoke
+	if err != nil {
+		return 0, fmt.Errorf("summarize history: %w", err)
+	}
+	summary := strings.TrimSpace(invoke.Response.Choices[0].Message.Content)
+	if summary == "" {
+		return 0, fmt.Errorf("summarize history: empty summary")
+	}
+	c.Summary = summary
+
+	replacement := Message{Role: "user", Content: "[此前对话摘要]\n" + summary}
+	rest := append([]Message{replacement}, s.messages[end:]...)
+	s.messages = append(s.messages[:start], rest...)
+	return end - start - 1, nil
+}
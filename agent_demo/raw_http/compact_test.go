@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestEstimateTextTokens(t *testing.T) {
+	if got := estimateTextTokens("abcdefgh"); got != 2 {
+		t.Fatalf("ascii = %d, want 2", got)
+	}
+	if got := estimateTextTokens("你好世界"); got != 4 {
+		t.Fatalf("cjk = %d, want 4", got)
+	}
+}
+
+func longText(n int) string { return strings.Repeat("x", n*4) }
+
+func TestSession_CompactDropKeepsToolPairs(t *testing.T) {
+	srv := newFakeServer(t,
+		fakeToolCalls(toolCall("call_1", "calculator", `{"expression":"1+1"}`)),
+		fakeText(longText(100)),
+		fakeText(longText(100)),
+		fakeText("ok"),
+	)
+	sess := NewSessionWithClient(srv.APIClient(), "qwen-plus")
+	sess.SetSystemPrompt("sys")
+	sess.EnableTools(BuiltinTools())
+	sess.SetContextPolicy(ContextPolicy{MaxPromptTokens: 400, Strategy: CompactDrop, KeepRecentTurns: 1})
+
+	for _, p := range []string{"first", "second", "third"} {
+		if _, err := sess.Chat(context.Background(), p); err != nil {
+			t.Fatalf("Chat(%s) error: %v", p, err)
+		}
+	}
+
+	c := sess.LastCompaction()
+	if c == nil || c.RemovedMessages != 4 || c.TokensAfter >= c.TokensBefore {
+		t.Fatalf("compaction = %+v", c)
+	}
+	reqs := srv.Requests()
+	sent := reqs[len(reqs)-1].Req.Messages
+	var roles []string
+	for _, m := range sent {
+		roles = append(roles, m.Role)
+	}
+	if got := strings.Join(roles, ","); got != "system,user,assistant,user" {
+		t.Fatalf("roles sent after compaction = %s", got)
+	}
+	if sent[1].Content != "second" {
+		t.Fatalf("kept wrong turn: %+v", sent[1])
+	}
+}
+
+func TestSession_CompactSummarize(t *testing.T) {
+	srv := newFakeServer(t,
+		fakeText(longText(200)),
+		fakeText("用户先打了招呼。"),
+		fakeText("ok"),
+	)
+	sess := NewSessionWithClient(srv.APIClient(), "qwen-plus")
+	sess.SetSystemPrompt("sys")
+	sess.SetContextPolicy(ContextPolicy{MaxPromptTokens: 100, Strategy: CompactSummarize})
+
+	if _, err := sess.Chat(context.Background(), "hello"); err != nil {
+		t.Fatalf("turn1 error: %v", err)
+	}
+	if _, err := sess.Chat(context.Background(), "next"); err != nil {
+		t.Fatalf("turn2 error: %v", err)
+	}
+
+	c := sess.LastCompaction()
+	if c == nil || c.Err != nil || c.Summary != "用户先打了招呼。" || c.Invoke == nil {
+		t.Fatalf("compaction = %+v", c)
+	}
+	reqs := srv.Requests()
+	summaryReq := reqs[1].Req
+	if len(summaryReq.Messages) != 2 || summaryReq.Messages[0].Content != DefaultSummaryPrompt || !strings.Contains(summaryReq.Messages[1].Content, "hello") {
+		t.Fatalf("summary request = %+v", summaryReq)
+	}
+	msgs := sess.Messages()
+	if len(msgs) != 4 || !strings.Contains(msgs[1].Content, "用户先打了招呼。") || msgs[2].Content != "next" {
+		t.Fatalf("messages = %+v", msgs)
+	}
+}
+
+func TestSession_CompactSummarizeFallsBackToDrop(t *testing.T) {
+	srv := newFakeServer(t,
+		fakeText(longText(200)),
+		fakeError(500, `{"error":{"message":"boom"}}`),
+		fakeText("ok"),
+	)
+	sess := NewSessionWithClient(srv.APIClient(), "qwen-plus")
+	sess.SetContextPolicy(ContextPolicy{MaxPromptTokens: 100, Strategy: CompactSummarize})
+
+	if _, err := sess.Chat(context.Background(), "hello"); err != nil {
+		t.Fatalf("turn1 error: %v", err)
+	}
+	if _, err := sess.Chat(context.Background(), "next"); err != nil {
+		t.Fatalf("turn2 error: %v", err)
+	}
+	if c := sess.LastCompaction(); c == nil || c.Err == nil || c.RemovedMessages != 2 {
+		t.Fatalf("compaction = %+v", c)
+	}
+	if msgs := sess.Messages(); len(msgs) != 2 || msgs[0].Content != "next" {
+		t.Fatalf("messages = %+v", msgs)
+	}
+}
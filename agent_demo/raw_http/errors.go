@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type ErrorKind string
+
+const (
+	ErrorKindUnknown        ErrorKind = "unknown"
+	ErrorKindAuth           ErrorKind = "auth"
+	ErrorKindRateLimit      ErrorKind = "rate_limit"
+	ErrorKindQuota          ErrorKind = "quota"
+	ErrorKindContextLength  ErrorKind = "context_length"
+	ErrorKindContentFilter  ErrorKind = "content_filter"
+	ErrorKindInvalidRequest ErrorKind = "invalid_request"
+	ErrorKindServer         ErrorKind = "server"
+)
+
+type APIError struct {
+	StatusCode int
+	Type       string
+	Code       string
+	Message    string
+	RequestID  string
+	Body       []byte
+	Kind       ErrorKind
+}
+
+func (e *APIError) Error() string {
+	msg := strings.TrimSpace(e.Message)
+	if msg == "" {
+		msg = strings.TrimSpace(string(e.Body))
+	}
+	if msg == "" {
+		msg = http.StatusText(e.StatusCode)
+	}
+	var tags []string
+	if e.Code != "" {
+		tags = append(tags, e.Code)
+	} else if e.Type != "" {
+		tags = append(tags, e.Type)
+	}
+	if e.RequestID != "" {
+		tags = append(tags, "request_id="+e.RequestID)
+	}
+	prefix := "api error"
+	if e.StatusCode < 200 || e.StatusCode >= 300 {
+		prefix = fmt.Sprintf("http %d", e.StatusCode)
+	}
+	if len(tags) > 0 {
+		return fmt.Sprintf("%s (%s): %s", prefix, strings.Join(tags, ", "), msg)
+	}
+	return fmt.Sprintf("%s: %s", prefix, msg)
+}
+
+func ErrorKindOf(err error) ErrorKind {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Kind
+	}
+	return ErrorKindUnknown
+}
+
+type apiErrorBody struct {
+	Message string      `json:"message,omitempty"`
+	Type    string      `json:"type,omitempty"`
+	Code    interface{} `json:"code,omitempty"`
+}
+
+func newAPIError(resp *http.Response, body []byte) *APIError {
+	e := &APIError{
+		StatusCode: resp.StatusCode,
+		Body:       body,
+		RequestID:  requestIDFromHeader(resp.Header),
+	}
+
+	var payload struct {
+		Error     *apiErrorBody `json:"error,omitempty"`
+		Code      string        `json:"code,omitempty"`
+		Message   string        `json:"message,omitempty"`
+		RequestID string        `json:"request_id,omitempty"`
+	}
+	if err := json.Unmarshal(body, &payload); err == nil {
+		if payload.Error != nil {
+			e.fill(payload.Error)
+		} else {
+			e.Code = payload.Code
+			e.Message = payload.Message
+		}
+		if e.RequestID == "" {
+			e.RequestID = payload.RequestID
+		}
+	}
+	e.Kind = classifyAPIError(e)
+	return e
+}
+
+func newBodyAPIError(resp *http.Response, body []byte, errBody *apiErrorBody) *APIError {
+	e := &APIError{
+		StatusCode: resp.StatusCode,
+		Body:       body,
+		RequestID:  requestIDFromHeader(resp.Header),
+	}
+	e.fill(errBody)
+	e.Kind = classifyAPIError(e)
+	return e
+}
+
+func (e *APIError) fill(body *apiErrorBody) {
+	e.Message = strings.TrimSpace(body.Message)
+	e.Type = body.Type
+	switch code := body.Code.(type) {
+	case nil:
+	case string:
+		e.Code = code
+	default:
+		e.Code = fmt.Sprint(code)
+	}
+}
+
+func requestIDFromHeader(h http.Header) string {
+	for _, k := range []string{"X-Request-Id", "X-Dashscope-Request-Id", "Request-Id"} {
+		if v := strings.TrimSpace(h.Get(k)); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func classifyAPIError(e *APIError) ErrorKind {
+	code := strings.ToLower(e.Code)
+	typ := strings.ToLower(e.Type)
+	msg := strings.ToLower(e.Message)
+
+	switch {
+	case code == "context_length_exceeded",
+		strings.Contains(msg, "maximum context length"),
+		strings.Contains(msg, "range of input length"),
+		strings.Contains(msg, "input length should be"),
+		strings.Contains(msg, "too many tokens"),
+		strings.Contains(msg, "prompt is too long"):
+		return ErrorKindContextLength
+	case code == "content_filter",
+		code == "data_inspection_failed",
+		code == "datainspectionfailed",
+		strings.Contains(msg, "inappropriate content"):
+		return ErrorKindContentFilter
+	case code == "insufficient_quota",
+		code == "arrearage",
+		strings.HasPrefix(code, "allocationquota"):
+		return ErrorKindQuota
+	case e.StatusCode == http.StatusTooManyRequests,
+		code == "rate_limit_exceeded",
+		strings.HasPrefix(code, "throttling"),
+		typ == "rate_limit_error":
+		return ErrorKindRateLimit
+	case e.StatusCode == http.StatusUnauthorized,
+		e.StatusCode == http.StatusForbidden,
+		code == "invalid_api_key",
+		code == "invalidapikey",
+		typ == "authentication_error":
+		return ErrorKindAuth
+	case e.StatusCode >= 500:
+		return ErrorKindServer
+	case e.StatusCode == http.StatusBadRequest,
+		e.StatusCode == http.StatusNotFound,
+		e.StatusCode == http.StatusUnprocessableEntity,
+		typ == "invalid_request_error":
+		return ErrorKindInvalidRequest
+	}
+	return ErrorKindUnknown
+}
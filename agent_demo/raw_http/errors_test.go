@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNewAPIError_Classification(t *testing.T) {
+	cases := []struct {
+		status int
+		body   string
+		want   ErrorKind
+	}{
+		{401, `{"error":{"message":"Incorrect API key provided.","type":"invalid_request_error","code":"invalid_api_key"}}`, ErrorKindAuth},
+		{429, `{"error":{"message":"Requests rate limit exceeded","type":"requests","code":"rate_limit_exceeded"}}`, ErrorKindRateLimit},
+		{429, `{"code":"Throttling.RateQuota","message":"Requests rate limit exceeded","request_id":"r-1"}`, ErrorKindRateLimit},
+		{400, `{"error":{"message":"This model's maximum context length is 8192 tokens.","type":"invalid_request_error","code":"context_length_exceeded"}}`, ErrorKindContextLength},
+		{400, `{"error":{"message":"Range of input length should be [1, 30720]","type":"invalid_request_error","code":"invalid_parameter_error"}}`, ErrorKindContextLength},
+		{400, `{"error":{"message":"Input data may contain inappropriate content.","type":"data_inspection_failed","code":"data_inspection_failed"}}`, ErrorKindContentFilter},
+		{403, `{"error":{"message":"Access denied, please make sure your account is in good standing.","code":"Arrearage"}}`, ErrorKindQuota},
+		{400, `{"error":{"message":"model not found","type":"invalid_request_error"}}`, ErrorKindInvalidRequest},
+		{502, `bad gateway`, ErrorKindServer},
+	}
+	for _, c := range cases {
+		resp := &http.Response{StatusCode: c.status, Header: http.Header{}}
+		got := newAPIError(resp, []byte(c.body))
+		if got.Kind != c.want {
+			t.Fatalf("status=%d body=%s: kind = %s, want %s", c.status, c.body, got.Kind, c.want)
+		}
+	}
+}
+
+func TestClientInvoke_APIErrorAs(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "req-42")
+		w.WriteHeader(http.StatusUnauthorized)
+		io.WriteString(w, `{"error":{"message":"Incorrect API key provided.","type":"invalid_request_error","code":"invalid_api_key"}}`)
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL, "test-key", 5*time.Second)
+	_, err := client.Invoke(context.Background(), ChatCompletionRequest{Model: "qwen-plus"})
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("err = %v, want *APIError", err)
+	}
+	if apiErr.StatusCode != 401 || apiErr.Code != "invalid_api_key" || apiErr.RequestID != "req-42" || apiErr.Kind != ErrorKindAuth {
+		t.Fatalf("apiErr = %+v", apiErr)
+	}
+	if !strings.Contains(err.Error(), "http 401") || !strings.Contains(err.Error(), "Incorrect API key") {
+		t.Fatalf("err = %q", err.Error())
+	}
+}
+
+func TestSessionChat_TrimsHistoryOnContextLength(t *testing.T) {
+	const maxMessages = 4
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ChatCompletionRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if len(req.Messages) > maxMessages {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `{"error":{"message":"maximum context length exceeded","code":"context_length_exceeded"}}`)
+			return
+		}
+		fmt.Fprintf(w, `{"choices":[{"message":{"role":"assistant","content":"reply %d"},"finish_reason":"stop"}]}`, len(req.Messages))
+	}))
+	defer srv.Close()
+
+	sess := &Session{client: NewClient(srv.URL, "test-key", 5*time.Second), model: "qwen-plus", maxSteps: 4}
+	sess.client.Retry = RetryPolicy{}
+	sess.SetSystemPrompt("sys")
+
+	for i := 0; i < 4; i++ {
+		if _, err := sess.Chat(context.Background(), fmt.Sprintf("q%d", i)); err != nil {
+			t.Fatalf("turn %d error: %v", i, err)
+		}
+	}
+	msgs := sess.Messages()
+	if len(msgs) > maxMessages+1 {
+		t.Fatalf("history len = %d, want <= %d", len(msgs), maxMessages+1)
+	}
+	if msgs[0].Role != "system" {
+		t.Fatalf("system prompt dropped: %+v", msgs)
+	}
+	if last := msgs[len(msgs)-2]; last.Role != "user" || last.Content != "q3" {
+		t.Fatalf("latest user turn lost: %+v", msgs)
+	}
+}
+
+func TestSessionChat_NoRetryOnContextLengthAfterTools(t *testing.T) {
+	srv := newFakeServer(t,
+		fakeText("a0"),
+		fakeToolCalls(toolCall("call_1", "mutate", `{}`)),
+		fakeError(http.StatusBadRequest, `{"error":{"message":"maximum context length exceeded","code":"context_length_exceeded"}}`),
+		// A rerun of the turn would get these and call mutate again.
+		fakeToolCalls(toolCall("call_2", "mutate", `{}`)),
+		fakeText("done"),
+	)
+	var runs atomic.Int32
+	sess := NewSessionWithClient(srv.APIClient(), "qwen-plus")
+	sess.SetSystemPrompt("sys")
+	sess.EnableTools([]Tool{{Type: "function", Function: ToolFunction{Name: "mutate"}}}, map[string]ToolHandler{
+		"mutate": func(ctx context.Context, args json.RawMessage) (string, error) {
+			runs.Add(1)
+			return "ok", nil
+		},
+	})
+
+	if _, err := sess.Chat(context.Background(), "q0"); err != nil {
+		t.Fatalf("turn 0 error: %v", err)
+	}
+	_, err := sess.Chat(context.Background(), "q1")
+	if ErrorKindOf(err) != ErrorKindContextLength {
+		t.Fatalf("err = %v", err)
+	}
+	if n := runs.Load(); n != 1 {
+		t.Fatalf("mutate ran %d times", n)
+	}
+	if msgs := sess.Messages(); len(msgs) != 3 || msgs[1].Content != "q0" {
+		t.Fatalf("history = %+v", msgs)
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+type ReACTEventType string
+
+const (
+	EventStepStart    ReACTEventType = "step_start"
+	EventRequestSent  ReACTEventType = "request_sent"
+	EventResponse     ReACTEventType = "response"
+	EventToolStart    ReACTEventType = "tool_start"
+	EventToolFinish   ReACTEventType = "tool_finish"
+	EventToolDenied   ReACTEventType = "tool_denied"
+	EventLoopDetected ReACTEventType = "loop_detected"
+	EventFinal        ReACTEventType = "final"
+	EventLoopError    ReACTEventType = "error"
+)
+
+// ReACTEvent describes one point in the ReACT loop. Step is 1-based; only the
+// fields relevant to Type are set.
+type ReACTEvent struct {
+	Type ReACTEventType
+	Time time.Time
+	Step int
+
+	// request_sent
+	Request *ChatCompletionRequest
+
+	// response
+	Invoke *InvokeResult
+	Usage  *Usage
+
+	// tool_start, tool_finish, tool_denied
+	ToolCall *ToolCall
+	Result   string
+	Panic    *ToolPanic
+
+	// loop_detected
+	Loop *ToolLoop
+
+	// response, tool_finish
+	Duration time.Duration
+
+	// final
+	Final  string
+	Forced bool
+
+	// response, error
+	Err error
+}
+
+type ReACTObserver interface {
+	OnEvent(ReACTEvent)
+}
+
+type ReACTObserverFunc func(ReACTEvent)
+
+func (f ReACTObserverFunc) OnEvent(e ReACTEvent) { f(e) }
+
+// WithObserver subscribes obs to loop events. Events are delivered one at a
+// time (tool events from parallel calls are serialized), on the goroutine
+// that produced them, so a slow observer slows the loop down.
+func WithObserver(obs ReACTObserver) ReACTOption {
+	return func(c *reactConfig) {
+		if obs != nil {
+			c.observers = append(c.observers, obs)
+		}
+	}
+}
+
+func (c *reactConfig) emit(e ReACTEvent) {
+	if len(c.observers) == 0 {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	c.emitMu.Lock()
+	defer c.emitMu.Unlock()
+	for _, o := range c.observers {
+		o.OnEvent(e)
+	}
+}
+
+func (c *reactConfig) emitResponse(step int, invoke *InvokeResult, err error) {
+	e := ReACTEvent{Type: EventResponse, Step: step, Invoke: invoke, Err: err}
+	if invoke != nil {
+		e.Duration = invoke.Duration
+		e.Usage = invoke.Response.Usage
+		if e.Duration == 0 {
+			for _, at := range invoke.Attempts {
+				e.Duration += at.Duration + at.Delay
+			}
+		}
+	}
+	c.emit(e)
+}
+
+// NewLiveRenderer returns an observer that prints a line per event to w, for
+// following a run while it is still in progress.
+func NewLiveRenderer(w io.Writer) ReACTObserver {
+	var mu sync.Mutex
+	return ReACTObserverFunc(func(e ReACTEvent) {
+		line := renderEventLine(e)
+		if line == "" {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprintln(w, line)
+	})
+}
+
+func renderEventLine(e ReACTEvent) string {
+	prefix := fmt.Sprintf("[step %d]", e.Step)
+	switch e.Type {
+	case EventStepStart:
+		return prefix + " start"
+	case EventRequestSent:
+		if e.Request == nil {
+			return prefix + " -> request"
+		}
+		if e.Request.ToolChoice != nil {
+			return fmt.Sprintf("%s -> request (messages=%d, tools=%d, tool_choice=%s)", prefix, len(e.Request.Messages), len(e.Request.Tools), e.Request.ToolChoice)
+		}
+		return fmt.Sprintf("%s -> request (messages=%d, tools=%d)", prefix, len(e.Request.Messages), len(e.Request.Tools))
+	case EventResponse:
+		if e.Err != nil {
+			return fmt.Sprintf("%s <- error after %s: %v", prefix, e.Duration.Round(time.Millisecond), e.Err)
+		}
+		line := fmt.Sprintf("%s <- response (latency=%s", prefix, e.Duration.Round(time.Millisecond))
+		if e.Invoke != nil && len(e.Invoke.Response.Choices) > 0 {
+			line += ", finish=" + e.Invoke.Response.Choices[0].FinishReason
+		}
+		if e.Usage != nil && e.Usage.TotalTokens > 0 {
+			line += fmt.Sprintf(", tokens=%d/%d%s", e.Usage.PromptTokens, e.Usage.CompletionTokens, usageDetail(e.Usage))
+		}
+		return line + ")"
+	case EventToolStart:
+		return fmt.Sprintf("%s tool %s (id=%s) %s", prefix, e.ToolCall.Function.Name, e.ToolCall.ID, oneLine(e.ToolCall.Function.Arguments, 120))
+	case EventToolFinish:
+		status := "done"
+		if e.Panic != nil {
+			status = "PANIC"
+		}
+		return fmt.Sprintf("%s tool %s %s in %s: %s", prefix, e.ToolCall.Function.Name, status, e.Duration.Round(time.Millisecond), oneLine(e.Result, 120))
+	case EventToolDenied:
+		return fmt.Sprintf("%s tool %s (id=%s) %s", prefix, e.ToolCall.Function.Name, e.ToolCall.ID, e.Result)
+	case EventLoopDetected:
+		action := "injecting a corrective message"
+		if e.Loop.Stopped {
+			action = "stopping"
+		}
+		return fmt.Sprintf("%s loop detected (%s), %s", prefix, e.Loop, action)
+	case EventFinal:
+		if e.Forced {
+			return fmt.Sprintf("%s final (forced after max steps): %s", prefix, oneLine(e.Final, 200))
+		}
+		return fmt.Sprintf("%s final: %s", prefix, oneLine(e.Final, 200))
+	case EventLoopError:
+		return fmt.Sprintf("%s error: %v", prefix, e.Err)
+	}
+	return ""
+}
+
+func oneLine(s string, max int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > max {
+		return string(r[:max]) + "..."
+	}
+	return s
+}
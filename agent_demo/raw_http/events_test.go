@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []ReACTEvent
+}
+
+func (r *eventRecorder) OnEvent(e ReACTEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+}
+
+func (r *eventRecorder) types() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []string
+	for _, e := range r.events {
+		out = append(out, string(e.Type))
+	}
+	return strings.Join(out, ",")
+}
+
+func TestDoReACT_Offline_EmitsEvents(t *testing.T) {
+	srv := newFakeServer(t,
+		FakeResponse{
+			Message:      Message{Role: "assistant", ToolCalls: []ToolCall{toolCall("call_1", "calculator", `{"expression":"1+1"}`), toolCall("call_2", "slow", `{}`)}},
+			FinishReason: "tool_calls",
+			Usage:        &Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15},
+		},
+		fakeText("2"),
+	)
+	tools, handlers := BuiltinTools()
+	tools = append(tools, Tool{Type: "function", Function: ToolFunction{Name: "slow"}})
+	handlers["slow"] = func(ctx context.Context, args json.RawMessage) (string, error) {
+		time.Sleep(15 * time.Millisecond)
+		return "zzz", nil
+	}
+
+	rec := &eventRecorder{}
+	if _, err := doReACT(context.Background(), srv.APIClient(), "qwen-plus", "sys", "1+1?", tools, handlers, 0, 4, WithObserver(rec)); err != nil {
+		t.Fatalf("doReACT error: %v", err)
+	}
+
+	var step1Tools []string
+	var slowFinish *ReACTEvent
+	for i, e := range rec.events {
+		if e.Time.IsZero() {
+			t.Fatalf("event %d has no time", i)
+		}
+		if e.Type == EventToolStart || e.Type == EventToolFinish {
+			if e.Step != 1 {
+				t.Fatalf("tool event step = %d", e.Step)
+			}
+			step1Tools = append(step1Tools, string(e.Type))
+		}
+		if e.Type == EventToolFinish && e.ToolCall.Function.Name == "slow" {
+			slowFinish = &rec.events[i]
+		}
+	}
+	if len(step1Tools) != 4 {
+		t.Fatalf("tool events = %v", step1Tools)
+	}
+	if slowFinish == nil || slowFinish.Result != "zzz" || slowFinish.Duration < 15*time.Millisecond {
+		t.Fatalf("slow finish = %+v", slowFinish)
+	}
+
+	ev := rec.events
+	if ev[0].Type != EventStepStart || ev[1].Type != EventRequestSent || len(ev[1].Request.Tools) != 3 || ev[2].Type != EventResponse {
+		t.Fatalf("step 1 prefix = %s", rec.types())
+	}
+	if ev[2].Usage == nil || ev[2].Usage.TotalTokens != 15 || ev[2].Invoke == nil {
+		t.Fatalf("response event = %+v", ev[2])
+	}
+	last := ev[len(ev)-1]
+	if last.Type != EventFinal || last.Step != 2 || last.Final != "2" {
+		t.Fatalf("last event = %+v", last)
+	}
+	if got := rec.types(); !strings.HasSuffix(got, "step_start,request_sent,response,final") {
+		t.Fatalf("events = %s", got)
+	}
+}
+
+func TestSession_Offline_ForwardsEvents(t *testing.T) {
+	srv := newFakeServer(t,
+		fakeText("hello"),
+		fakeError(400, `{"error":{"message":"bad","type":"invalid_request_error"}}`),
+	)
+	sess := NewSessionWithClient(srv.APIClient(), "qwen-plus")
+	rec := &eventRecorder{}
+	sess.SetObserver(rec)
+
+	if _, err := sess.Chat(context.Background(), "hi"); err != nil {
+		t.Fatal(err)
+	}
+	if got := rec.types(); got != "step_start,request_sent,response,final" {
+		t.Fatalf("events = %s", got)
+	}
+	if _, err := sess.Chat(context.Background(), "again"); err == nil {
+		t.Fatal("expected error")
+	}
+	if got := rec.types(); !strings.HasSuffix(got, "response,error") {
+		t.Fatalf("events = %s", got)
+	}
+
+	var buf bytes.Buffer
+	live := NewLiveRenderer(&buf)
+	for _, e := range rec.events {
+		live.OnEvent(e)
+	}
+	out := buf.String()
+	for _, want := range []string{"[step 1] -> request (messages=1, tools=0)", "[step 1] final: hello", "[step 1] error: http 400"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("live output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestSession_Offline_TransportErrorEventHasDuration(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	client := NewClient("http://"+addr, "test-key", time.Second)
+	client.Retry = RetryPolicy{MaxAttempts: 2, BaseDelay: 5 * time.Millisecond}
+	sess := NewSessionWithClient(client, "qwen-plus")
+	rec := &eventRecorder{}
+	sess.SetObserver(rec)
+	if _, err := sess.Chat(context.Background(), "hi"); err == nil {
+		t.Fatal("expected error")
+	}
+	for _, e := range rec.events {
+		if e.Type == EventResponse {
+			if e.Err == nil || e.Invoke == nil || len(e.Invoke.Attempts) != 2 || e.Duration < 5*time.Millisecond {
+				t.Fatalf("response event = %+v", e)
+			}
+			return
+		}
+	}
+	t.Fatalf("no response event: %s", rec.types())
+}
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+)
+
+type ReACTOption func(*reactConfig)
+
+type reactConfig struct {
+	maxParallel  int
+	toolTimeout  time.Duration
+	toolTimeouts map[string]time.Duration
+	stepTimeout  time.Duration
+	serialTools  map[string]bool
+
+	skipArgValidation bool
+	schemas           map[string]map[string]interface{}
+
+	observers []ReACTObserver
+	emitMu    sync.Mutex
+
+	approver ToolApprover
+
+	loopMaxRepeats int
+	loopAction     LoopAction
+
+	forceFinal       bool
+	forceFinalPrompt string
+
+	// slots and handlerLock outlive a step: a handler abandoned after its
+	// timeout keeps its parallelism slot (and, for a serial tool, exclusive
+	// access) until it actually returns.
+	slots       chan struct{}
+	handlerLock handlerLock
+
+	sampling       SamplingParams
+	responseFormat *ResponseFormat
+	toolChoice     ToolChoiceStrategy
+}
+
+func newReACTConfig(opts []ReACTOption) *reactConfig {
+	cfg := &reactConfig{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(cfg)
+		}
+	}
+	return cfg
+}
+
+func WithMaxParallelTools(n int) ReACTOption {
+	return func(c *reactConfig) { c.maxParallel = n }
+}
+
+func WithToolTimeout(d time.Duration) ReACTOption {
+	return func(c *reactConfig) { c.toolTimeout = d }
+}
+
+func WithToolTimeoutFor(name string, d time.Duration) ReACTOption {
+	return func(c *reactConfig) {
+		if c.toolTimeouts == nil {
+			c.toolTimeouts = map[string]time.Duration{}
+		}
+		c.toolTimeouts[name] = d
+	}
+}
+
+func WithStepTimeout(d time.Duration) ReACTOption {
+	return func(c *reactConfig) { c.stepTimeout = d }
+}
+
+func WithSerialTools(names ...string) ReACTOption {
+	return func(c *reactConfig) {
+		if c.serialTools == nil {
+			c.serialTools = map[string]bool{}
+		}
+		for _, n := range names {
+			c.serialTools[n] = true
+		}
+	}
+}
+
+func WithoutArgValidation() ReACTOption {
+	return func(c *reactConfig) { c.skipArgValidation = true }
+}
+
+func (c *reactConfig) loadSchemas(tools []Tool) error {
+	if c.skipArgValidation {
+		return nil
+	}
+	c.schemas = make(map[string]map[string]interface{}, len(tools))
+	for _, t := range tools {
+		if t.Function.Parameters == nil {
+			continue
+		}
+		norm, err := normalizeSchema(t.Function.Parameters)
+		if err != nil {
+			return fmt.Errorf("tool %s: invalid parameters schema: %w", t.Function.Name, err)
+		}
+		c.schemas[t.Function.Name] = norm
+	}
+	return nil
+}
+
+func (c *reactConfig) timeoutFor(name string) time.Duration {
+	if d, ok := c.toolTimeouts[name]; ok {
+		return d
+	}
+	return c.toolTimeout
+}
+
+// executeToolCalls runs one step's tool calls and returns the tool messages in
+// call order. Regular calls run concurrently (bounded by maxParallel); calls
+// to serial tools run afterwards, one at a time, so they never overlap with
+// any other handler. Arguments rewritten by the approver are written back
+// into calls. Approval happens before the step timeout starts, so time spent
+// at an interactive prompt does not eat into the handlers' deadline.
+func executeToolCalls(ctx context.Context, cfg *reactConfig, step int, calls []ToolCall, handlers map[string]ToolHandler) ([]Message, []ToolPanic) {
+	denied := approveToolCalls(ctx, cfg, step, calls)
+	if cfg.stepTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.stepTimeout)
+		defer cancel()
+	}
+
+	results := make([]Message, len(calls))
+	panics := make([]*ToolPanic, len(calls))
+	var serial []int
+
+	if cfg.maxParallel > 0 && cfg.slots == nil {
+		cfg.slots = make(chan struct{}, cfg.maxParallel)
+	}
+	var wg sync.WaitGroup
+	for i, tc := range calls {
+		if denied[i] != "" {
+			results[i] = toolResultMessage(tc, denied[i])
+			continue
+		}
+		if cfg.serialTools[tc.Function.Name] {
+			serial = append(serial, i)
+			continue
+		}
+		wg.Add(1)
+		go func(i int, tc ToolCall) {
+			defer wg.Done()
+			results[i], panics[i] = runObservedToolCall(ctx, cfg, step, tc, handlers, false)
+		}(i, tc)
+	}
+	wg.Wait()
+
+	for _, i := range serial {
+		results[i], panics[i] = runObservedToolCall(ctx, cfg, step, calls[i], handlers, true)
+	}
+
+	var recovered []ToolPanic
+	for i, p := range panics {
+		if p != nil {
+			p.Step = step
+			p.CallIndex = i
+			recovered = append(recovered, *p)
+		}
+	}
+	return results, recovered
+}
+
+// toolErrorPrefixes start every tool message the loop writes for a call that
+// did not produce a result (handler errors, timeouts, denials, skips).
+var toolErrorPrefixes = []string{"tool error:", "tool not found:", "tool call denied:", "tool call skipped:", `{"error":"tool_panic"`}
+
+// isToolErrorContent reports whether a tool message reports a failure, for
+// protocols such as Anthropic's that flag error results explicitly.
+func isToolErrorContent(content string) bool {
+	for _, p := range toolErrorPrefixes {
+		if strings.HasPrefix(content, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func toolResultMessage(tc ToolCall, content string) Message {
+	return Message{
+		Role:       "tool",
+		ToolCallID: tc.ID,
+		Content:    content,
+	}
+}
+
+func runObservedToolCall(ctx context.Context, cfg *reactConfig, step int, tc ToolCall, handlers map[string]ToolHandler, exclusive bool) (Message, *ToolPanic) {
+	cfg.emit(ReACTEvent{Type: EventToolStart, Step: step, ToolCall: &tc})
+	started := time.Now()
+	msg, p := runToolCall(ctx, cfg, tc, handlers, exclusive)
+	cfg.emit(ReACTEvent{Type: EventToolFinish, Step: step, ToolCall: &tc, Result: msg.Content, Panic: p, Duration: time.Since(started)})
+	return msg, p
+}
+
+// runToolCall runs one handler under its timeout, which also bounds the wait
+// for a parallelism slot and the handler lock: a handler abandoned after its
+// timeout keeps both until it really returns, so a later call that cannot get
+// them in time fails instead of hanging. Exclusive calls take no slot but wait
+// until no other handler of the run is alive.
+func runToolCall(ctx context.Context, cfg *reactConfig, tc ToolCall, handlers map[string]ToolHandler, exclusive bool) (Message, *ToolPanic) {
+	handler, ok := handlers[tc.Function.Name]
+	if !ok {
+		return toolResultMessage(tc, fmt.Sprintf("tool not found: %s", tc.Function.Name)), nil
+	}
+	if err := ctx.Err(); err != nil {
+		return toolResultMessage(tc, toolCtxError(tc, err, 0)), nil
+	}
+	if schema, ok := cfg.schemas[tc.Function.Name]; ok {
+		if err := validateNormalizedArgs(schema, tc.Function.Arguments); err != nil {
+			return toolResultMessage(tc, fmt.Sprintf("tool error: invalid arguments for %s: %v", tc.Function.Name, err)), nil
+		}
+	}
+
+	timeout := cfg.timeoutFor(tc.Function.Name)
+	callCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		callCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	release := func() {}
+	if cfg.slots != nil && !exclusive {
+		select {
+		case cfg.slots <- struct{}{}:
+			release = func() { <-cfg.slots }
+		case <-callCtx.Done():
+			return toolResultMessage(tc, toolStartError(ctx, tc, timeout)), nil
+		}
+	}
+	if err := cfg.handlerLock.acquire(callCtx, exclusive); err != nil {
+		release()
+		return toolResultMessage(tc, toolStartError(ctx, tc, timeout)), nil
+	}
+
+	type outcome struct {
+		out   string
+		err   error
+		panic *ToolPanic
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		defer release()
+		defer cfg.handlerLock.release(exclusive)
+		defer func() {
+			if r := recover(); r != nil {
+				done <- outcome{panic: &ToolPanic{
+					ToolCallID: tc.ID,
+					Tool:       tc.Function.Name,
+					Value:      fmt.Sprint(r),
+					Stack:      string(debug.Stack()),
+				}}
+			}
+		}()
+		out, err := handler(callCtx, json.RawMessage(tc.Function.Arguments))
+		done <- outcome{out: out, err: err}
+	}()
+
+	select {
+	case o := <-done:
+		if o.panic != nil {
+			return toolResultMessage(tc, toolPanicContent(o.panic)), o.panic
+		}
+		if o.err != nil {
+			return toolResultMessage(tc, fmt.Sprintf("tool error: %v", o.err)), nil
+		}
+		return toolResultMessage(tc, o.out), nil
+	case <-callCtx.Done():
+		if ctx.Err() == nil {
+			return toolResultMessage(tc, toolCtxError(tc, callCtx.Err(), timeout)), nil
+		}
+		return toolResultMessage(tc, toolCtxError(tc, ctx.Err(), 0)), nil
+	}
+}
+
+// handlerLock lets regular handlers run together while a serial handler
+// runs alone. Unlike sync.RWMutex, waiting can be abandoned via ctx.
+type handlerLock struct {
+	mu        sync.Mutex
+	shared    int
+	exclusive bool
+	changed   chan struct{}
+}
+
+func (l *handlerLock) acquire(ctx context.Context, exclusive bool) error {
+	for {
+		l.mu.Lock()
+		if !l.exclusive && (!exclusive || l.shared == 0) {
+			if exclusive {
+				l.exclusive = true
+			} else {
+				l.shared++
+			}
+			l.mu.Unlock()
+			return nil
+		}
+		if l.changed == nil {
+			l.changed = make(chan struct{})
+		}
+		changed := l.changed
+		l.mu.Unlock()
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (l *handlerLock) release(exclusive bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if exclusive {
+		l.exclusive = false
+	} else {
+		l.shared--
+	}
+	if l.changed != nil {
+		close(l.changed)
+		l.changed = nil
+	}
+}
+
+// ToolPanic records a recovered handler panic. Step and CallIndex (the
+// position in that step's tool_calls) identify the call; ToolCallID is only
+// informational since models may reuse or omit IDs.
+type ToolPanic struct {
+	Step       int
+	CallIndex  int
+	ToolCallID string
+	Tool       string
+	Value      string
+	Stack      string
+}
+
+func toolPanicContent(p *ToolPanic) string {
+	b, err := json.Marshal(map[string]string{
+		"error":   "tool_panic",
+		"tool":    p.Tool,
+		"message": fmt.Sprintf("the tool crashed while handling this call: %s", p.Value),
+	})
+	if err != nil {
+		return fmt.Sprintf("tool error: %s panicked: %s", p.Tool, p.Value)
+	}
+	return string(b)
+}
+
+// toolStartError reports a call that timed out waiting for a slot or the
+// handler lock, i.e. before its handler ever ran.
+func toolStartError(ctx context.Context, tc ToolCall, timeout time.Duration) string {
+	if err := ctx.Err(); err != nil {
+		return toolCtxError(tc, err, 0)
+	}
+	return fmt.Sprintf("tool error: %s could not start within %s: earlier tool calls are still running", tc.Function.Name, timeout)
+}
+
+func toolCtxError(tc ToolCall, err error, timeout time.Duration) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		if timeout > 0 {
+			return fmt.Sprintf("tool error: %s timed out after %s", tc.Function.Name, timeout)
+		}
+		return fmt.Sprintf("tool error: %s did not finish before the step deadline", tc.Function.Name)
+	}
+	return fmt.Sprintf("tool error: %s canceled: %v", tc.Function.Name, err)
+}
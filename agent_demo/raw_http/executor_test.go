@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestExecuteToolCalls_MaxParallel(t *testing.T) {
+	var running, peak atomic.Int32
+	handlers := map[string]ToolHandler{
+		"work": func(ctx context.Context, args json.RawMessage) (string, error) {
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			running.Add(-1)
+			return "ok", nil
+		},
+	}
+	var calls []ToolCall
+	for i := 0; i < 12; i++ {
+		calls = append(calls, toolCall(fmt.Sprintf("call_%d", i), "work", `{}`))
+	}
+
+	cfg := newReACTConfig([]ReACTOption{WithMaxParallelTools(3)})
+	results, _ := executeToolCalls(context.Background(), cfg, 1, calls, handlers)
+	if got := peak.Load(); got > 3 {
+		t.Fatalf("peak concurrency = %d, want <= 3", got)
+	}
+	for i, m := range results {
+		if m.ToolCallID != calls[i].ID || m.Content != "ok" {
+			t.Fatalf("result %d = %+v", i, m)
+		}
+	}
+}
+
+func TestExecuteToolCalls_Timeouts(t *testing.T) {
+	block := func(ctx context.Context, args json.RawMessage) (string, error) {
+		select {}
+	}
+	handlers := map[string]ToolHandler{
+		"hang": block,
+		"slow": func(ctx context.Context, args json.RawMessage) (string, error) {
+			select {
+			case <-time.After(time.Second):
+				return "late", nil
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+		},
+		"fast": func(ctx context.Context, args json.RawMessage) (string, error) { return "fast", nil },
+	}
+	calls := []ToolCall{
+		toolCall("a", "hang", `{}`),
+		toolCall("b", "slow", `{}`),
+		toolCall("c", "fast", `{}`),
+	}
+	cfg := newReACTConfig([]ReACTOption{
+		WithToolTimeout(20 * time.Millisecond),
+		WithToolTimeoutFor("slow", 30*time.Millisecond),
+	})
+
+	start := time.Now()
+	results, _ := executeToolCalls(context.Background(), cfg, 1, calls, handlers)
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("step took %s, hung handler was not abandoned", elapsed)
+	}
+	if results[0].Content != "tool error: hang timed out after 20ms" {
+		t.Fatalf("hang result = %q", results[0].Content)
+	}
+	if results[1].Content != "tool error: slow timed out after 30ms" {
+		t.Fatalf("slow result = %q", results[1].Content)
+	}
+	if results[2].Content != "fast" {
+		t.Fatalf("fast result = %q", results[2].Content)
+	}
+}
+
+func TestExecuteToolCalls_StepTimeout(t *testing.T) {
+	handlers := map[string]ToolHandler{
+		"hang": func(ctx context.Context, args json.RawMessage) (string, error) { select {} },
+	}
+	cfg := newReACTConfig([]ReACTOption{WithStepTimeout(20 * time.Millisecond), WithSerialTools("hang")})
+	results, _ := executeToolCalls(context.Background(), cfg, 1, []ToolCall{toolCall("a", "hang", `{}`), toolCall("b", "hang", `{}`)}, handlers)
+	for _, m := range results {
+		if !strings.Contains(m.Content, "did not finish before the step deadline") {
+			t.Fatalf("result = %q", m.Content)
+		}
+	}
+}
+
+func TestExecuteToolCalls_SerialTools(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		active  int
+		overlap bool
+		order   []string
+	)
+	track := func(name string) ToolHandler {
+		return func(ctx context.Context, args json.RawMessage) (string, error) {
+			mu.Lock()
+			active++
+			if active > 1 && name == "db" {
+				overlap = true
+			}
+			order = append(order, name)
+			mu.Unlock()
+			time.Sleep(5 * time.Millisecond)
+			mu.Lock()
+			if active > 1 && name == "db" {
+				overlap = true
+			}
+			active--
+			mu.Unlock()
+			return name, nil
+		}
+	}
+	handlers := map[string]ToolHandler{"db": track("db"), "read": track("read")}
+	calls := []ToolCall{
+		toolCall("1", "db", `{}`),
+		toolCall("2", "read", `{}`),
+		toolCall("3", "db", `{}`),
+		toolCall("4", "read", `{}`),
+	}
+	cfg := newReACTConfig([]ReACTOption{WithSerialTools("db")})
+	results, _ := executeToolCalls(context.Background(), cfg, 1, calls, handlers)
+	if overlap {
+		t.Fatalf("serial tool overlapped with another handler (order=%v)", order)
+	}
+	for i, want := range []string{"db", "read", "db", "read"} {
+		if results[i].Content != want || results[i].ToolCallID != calls[i].ID {
+			t.Fatalf("result %d = %+v", i, results[i])
+		}
+	}
+}
+
+func TestExecuteToolCalls_AbandonedHandlersKeepTheirSlot(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		active  int
+		overlap bool
+	)
+	// stubborn ignores ctx and outlives its timeout.
+	stubborn := func(ctx context.Context, args json.RawMessage) (string, error) {
+		mu.Lock()
+		active++
+		overlap = overlap || active > 1
+		mu.Unlock()
+		time.Sleep(60 * time.Millisecond)
+		mu.Lock()
+		active--
+		mu.Unlock()
+		return "late", nil
+	}
+	handlers := map[string]ToolHandler{"stubborn": stubborn, "db": stubborn}
+
+	cfg := newReACTConfig([]ReACTOption{WithMaxParallelTools(1), WithToolTimeout(10 * time.Millisecond), WithToolTimeoutFor("db", time.Second), WithSerialTools("db")})
+	results, _ := executeToolCalls(context.Background(), cfg, 1, []ToolCall{toolCall("a", "stubborn", `{}`), toolCall("b", "stubborn", `{}`)}, handlers)
+	// Whichever call gets the slot first keeps it past the other's timeout.
+	got := results[0].Content + "\n" + results[1].Content
+	if !strings.Contains(got, "stubborn timed out") || !strings.Contains(got, "stubborn could not start") {
+		t.Fatalf("results = %+v", results)
+	}
+
+	// The serial call of the next step waits for the abandoned handler.
+	results, _ = executeToolCalls(context.Background(), cfg, 2, []ToolCall{toolCall("c", "db", `{}`)}, handlers)
+	if results[0].Content != "late" {
+		t.Fatalf("serial result = %q", results[0].Content)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if overlap {
+		t.Fatalf("an abandoned handler overlapped with the next one")
+	}
+}
+
+func TestDoReACT_Offline_HungHandlerDoesNotBlockLaterSteps(t *testing.T) {
+	srv := newFakeServer(t,
+		fakeToolCalls(toolCall("call_1", "hang", `{}`)),
+		fakeToolCalls(toolCall("call_2", "calculator", `{"expression":"1+1"}`)),
+		fakeText("done"),
+	)
+	tools, handlers := BuiltinTools()
+	tools = append(tools, Tool{Type: "function", Function: ToolFunction{Name: "hang"}})
+	block := make(chan struct{})
+	defer close(block)
+	// hang ignores ctx and keeps its slot after the timeout.
+	handlers["hang"] = func(ctx context.Context, args json.RawMessage) (string, error) {
+		<-block
+		return "", nil
+	}
+
+	done := make(chan struct{})
+	var (
+		res *ReACTResult
+		err error
+	)
+	go func() {
+		defer close(done)
+		res, err = doReACT(context.Background(), srv.APIClient(), "qwen-plus", "sys", "go", tools, handlers, 0, 4,
+			WithMaxParallelTools(1), WithToolTimeout(50*time.Millisecond))
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("doReACT hung waiting for a slot held by an abandoned handler")
+	}
+	if err != nil {
+		t.Fatalf("doReACT error: %v", err)
+	}
+	if res.Final != "done" {
+		t.Fatalf("final = %q", res.Final)
+	}
+	if got := res.Messages[5].Content; !strings.Contains(got, "calculator could not start within 50ms") {
+		t.Fatalf("second step result = %q", got)
+	}
+}
+
+func TestDoReACT_Offline_ToolTimeoutReportedToModel(t *testing.T) {
+	srv := newFakeServer(t,
+		fakeToolCalls(toolCall("call_1", "hang", `{}`)),
+		fakeText("gave up"),
+	)
+	tools := []Tool{{Type: "function", Function: ToolFunction{Name: "hang"}}}
+	handlers := map[string]ToolHandler{
+		"hang": func(ctx context.Context, args json.RawMessage) (string, error) { select {} },
+	}
+
+	res, err := doReACT(context.Background(), srv.APIClient(), "qwen-plus", "sys", "go", tools, handlers, 0, 4, WithToolTimeout(20*time.Millisecond))
+	if err != nil {
+		t.Fatalf("doReACT error: %v", err)
+	}
+	sent := srv.Requests()[1].Req.Messages
+	if got := sent[len(sent)-1]; got.Role != "tool" || !strings.Contains(got.Content, "timed out") {
+		t.Fatalf("tool message sent = %+v", got)
+	}
+	if res.Final != "gave up" {
+		t.Fatalf("final = %q", res.Final)
+	}
+}
+
+func TestDoReACT_Offline_RecoversToolPanic(t *testing.T) {
+	srv := newFakeServer(t,
+		fakeToolCalls(toolCall("call_1", "boom", `{}`), toolCall("call_2", "calculator", `{"expression":"2*2"}`)),
+		fakeText("recovered"),
+	)
+	tools, handlers := BuiltinTools()
+	tools = append(tools, Tool{Type: "function", Function: ToolFunction{Name: "boom"}})
+	handlers["boom"] = func(ctx context.Context, args json.RawMessage) (string, error) {
+		var m map[string]int
+		m["x"] = 1
+		return "", nil
+	}
+
+	res, err := doReACT(context.Background(), srv.APIClient(), "qwen-plus", "sys", "go", tools, handlers, 0, 4)
+	if err != nil {
+		t.Fatalf("doReACT error: %v", err)
+	}
+	if res.Final != "recovered" {
+		t.Fatalf("final = %q", res.Final)
+	}
+	if len(res.Panics) != 1 {
+		t.Fatalf("panics = %+v", res.Panics)
+	}
+	p := res.Panics[0]
+	if p.Step != 1 || p.ToolCallID != "call_1" || p.Tool != "boom" || !strings.Contains(p.Value, "nil map") || !strings.Contains(p.Stack, "goroutine") {
+		t.Fatalf("panic = %+v", p)
+	}
+
+	sent := srv.Requests()[1].Req.Messages
+	var toolMsg map[string]string
+	if err := json.Unmarshal([]byte(sent[3].Content), &toolMsg); err != nil || toolMsg["error"] != "tool_panic" || toolMsg["tool"] != "boom" {
+		t.Fatalf("tool message = %q", sent[3].Content)
+	}
+	if strings.Contains(sent[3].Content, "goroutine") {
+		t.Fatalf("stack trace leaked to the model: %q", sent[3].Content)
+	}
+	if sent[4].Content != "4" {
+		t.Fatalf("sibling tool result = %q", sent[4].Content)
+	}
+
+	out := RenderReACTResult(res)
+	if !strings.Contains(out, "tool[call_1] PANIC (step=1)") || !strings.Contains(out, "!! boom panicked") {
+		t.Fatalf("render:\n%s", out)
+	}
+}
+
+func TestDoReACT_Offline_PanicsWithReusedToolCallIDs(t *testing.T) {
+	srv := newFakeServer(t,
+		fakeToolCalls(toolCall("c", "boom", `{}`), toolCall("c", "calculator", `{"expression":"1+1"}`), toolCall("c", "boom", `{}`)),
+		fakeToolCalls(toolCall("c", "boom", `{}`)),
+		fakeText("done"),
+	)
+	tools, handlers := BuiltinTools()
+	tools = append(tools, Tool{Type: "function", Function: ToolFunction{Name: "boom"}})
+	handlers["boom"] = func(ctx context.Context, args json.RawMessage) (string, error) {
+		panic("kaboom")
+	}
+
+	res, err := doReACT(context.Background(), srv.APIClient(), "qwen-plus", "sys", "go", tools, handlers, 0, 4)
+	if err != nil {
+		t.Fatalf("doReACT error: %v", err)
+	}
+	if len(res.Panics) != 3 {
+		t.Fatalf("panics = %+v", res.Panics)
+	}
+	want := [][2]int{{1, 0}, {1, 2}, {2, 0}}
+	for i, p := range res.Panics {
+		if p.Step != want[i][0] || p.CallIndex != want[i][1] {
+			t.Fatalf("panic %d = step %d call %d, want %v", i, p.Step, p.CallIndex, want[i])
+		}
+	}
+
+	out := RenderReACTResult(res)
+	if n := strings.Count(out, "tool[c] PANIC"); n != 3 {
+		t.Fatalf("rendered %d panics:\n%s", n, out)
+	}
+	if !strings.Contains(out, "tool[c] PANIC (step=2)") {
+		t.Fatalf("render:\n%s", out)
+	}
+	if strings.Count(out, "!! boom panicked") != 3 {
+		t.Fatalf("render:\n%s", out)
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type FakeResponse struct {
+	Message      Message
+	FinishReason string
+	Usage        *Usage
+
+	Status  int
+	Body    string
+	Header  http.Header
+	Latency time.Duration
+}
+
+type FakeRequest struct {
+	Header http.Header
+	Body   []byte
+	Req    ChatCompletionRequest
+}
+
+type FakeServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	script   []FakeResponse
+	requests []FakeRequest
+}
+
+func newFakeServer(t testing.TB, script ...FakeResponse) *FakeServer {
+	t.Helper()
+	fs := &FakeServer{script: script}
+	fs.Server = httptest.NewServer(http.HandlerFunc(fs.serve))
+	t.Cleanup(fs.Close)
+	return fs
+}
+
+func (fs *FakeServer) APIClient() *Client {
+	c := NewClient(fs.URL, "test-key", 5*time.Second)
+	c.Retry = RetryPolicy{}
+	return c
+}
+
+func (fs *FakeServer) Push(script ...FakeResponse) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.script = append(fs.script, script...)
+}
+
+func (fs *FakeServer) Requests() []FakeRequest {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	out := make([]FakeRequest, len(fs.requests))
+	copy(out, fs.requests)
+	return out
+}
+
+func (fs *FakeServer) serve(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	rec := FakeRequest{Header: r.Header.Clone(), Body: body}
+	_ = json.Unmarshal(body, &rec.Req)
+
+	fs.mu.Lock()
+	fs.requests = append(fs.requests, rec)
+	var (
+		resp FakeResponse
+		ok   bool
+	)
+	if len(fs.script) > 0 {
+		resp, fs.script, ok = fs.script[0], fs.script[1:], true
+	}
+	n := len(fs.requests)
+	fs.mu.Unlock()
+
+	if !ok {
+		http.Error(w, fmt.Sprintf(`{"error":{"message":"fake: no scripted response for request %d"}}`, n), http.StatusInternalServerError)
+		return
+	}
+
+	if resp.Latency > 0 {
+		select {
+		case <-time.After(resp.Latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	for k, vs := range resp.Header {
+		for _, v := range vs {
+			w.Header().Add(k, v)
+		}
+	}
+	if resp.Status != 0 || resp.Body != "" {
+		if resp.Status == 0 {
+			resp.Status = http.StatusOK
+		}
+		w.WriteHeader(resp.Status)
+		io.WriteString(w, resp.Body)
+		return
+	}
+
+	msg := resp.Message
+	if msg.Role == "" {
+		msg.Role = "assistant"
+	}
+	finish := resp.FinishReason
+	if finish == "" {
+		finish = "stop"
+		if len(msg.ToolCalls) > 0 {
+			finish = "tool_calls"
+		}
+	}
+	id := fmt.Sprintf("chatcmpl-fake-%d", n)
+
+	if rec.Req.Stream {
+		w.Header().Set("Content-Type", "text/event-stream")
+		deltas := make([]ToolCallDelta, len(msg.ToolCalls))
+		for i, tc := range msg.ToolCalls {
+			deltas[i] = ToolCallDelta{Index: i, ID: tc.ID, Type: tc.Type, Function: tc.Function}
+		}
+		chunk := map[string]any{
+			"id":    id,
+			"model": rec.Req.Model,
+			"choices": []map[string]any{{
+				"index": 0,
+				"delta": map[string]any{
+					"role":              msg.Role,
+					"content":           msg.Content,
+					"reasoning_content": msg.ReasoningContent,
+					"tool_calls":        deltas,
+				},
+				"finish_reason": finish,
+			}},
+			"usage": resp.Usage,
+		}
+		b, _ := json.Marshal(chunk)
+		fmt.Fprintf(w, "data: %s\n\ndata: [DONE]\n\n", b)
+		return
+	}
+
+	out := ChatCompletionResponse{
+		ID:      id,
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   rec.Req.Model,
+		Choices: []Choice{{Message: msg, FinishReason: finish}},
+		Usage:   resp.Usage,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+func fakeText(content string) FakeResponse {
+	return FakeResponse{Message: Message{Role: "assistant", Content: content}}
+}
+
+func fakeToolCalls(calls ...ToolCall) FakeResponse {
+	return FakeResponse{Message: Message{Role: "assistant", ToolCalls: calls}}
+}
+
+func fakeError(status int, body string) FakeResponse {
+	return FakeResponse{Status: status, Body: body}
+}
+
+func toolCall(id, name, args string) ToolCall {
+	return ToolCall{ID: id, Type: "function", Function: ToolCallFunction{Name: name, Arguments: args}}
+}
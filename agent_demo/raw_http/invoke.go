@@ -3,7 +3,6 @@ package main
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -18,6 +17,8 @@ type Client struct {
 	Endpoint   string
 	APIKey     string
 	HTTPClient *http.Client
+	Retry      RetryPolicy
+	Provider   Provider
 }
 
 func NewClient(endpoint, apiKey string, timeout time.Duration) *Client {
@@ -30,6 +31,7 @@ func NewClient(endpoint, apiKey string, timeout time.Duration) *Client {
 		HTTPClient: &http.Client{
 			Timeout: timeout,
 		},
+		Retry: DefaultRetryPolicy(),
 	}
 }
 
@@ -56,20 +58,55 @@ type ToolCallFunction struct {
 }
 
 type Message struct {
-	Role             string     `json:"role"`
-	Content          string     `json:"content,omitempty"`
-	ReasoningContent string     `json:"reasoning_content,omitempty"`
-	ToolCalls        []ToolCall `json:"tool_calls,omitempty"`
-	ToolCallID       string     `json:"tool_call_id,omitempty"`
+	Role              string          `json:"role"`
+	Content           string          `json:"content,omitempty"`
+	Parts             []ContentPart   `json:"-"` // multimodal content; see UserMessage
+	ReasoningContent  string          `json:"reasoning_content,omitempty"`
+	ThinkingSignature string          `json:"thinking_signature,omitempty"`
+	ThinkingBlocks    []ThinkingBlock `json:"thinking_blocks,omitempty"`
+	ToolCalls         []ToolCall      `json:"tool_calls,omitempty"`
+	ToolCallID        string          `json:"tool_call_id,omitempty"`
+}
+
+// ThinkingBlock is one Anthropic thinking (or redacted_thinking) block with
+// its own signature; they must be replayed unchanged and in order.
+// ReasoningContent holds the same text joined for display.
+type ThinkingBlock struct {
+	Type      string `json:"type"`
+	Thinking  string `json:"thinking,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	Data      string `json:"data,omitempty"`
 }
 
 type ChatCompletionRequest struct {
-	Model       string    `json:"model"`
-	Messages    []Message `json:"messages"`
-	Temperature float64   `json:"temperature,omitempty"`
-	Stream      bool      `json:"stream,omitempty"`
-	Tools       []Tool    `json:"tools,omitempty"`
-	ToolChoice  string    `json:"tool_choice,omitempty"`
+	Model         string         `json:"model"`
+	Messages      []Message      `json:"messages"`
+	Stream        bool           `json:"stream,omitempty"`
+	StreamOptions *StreamOptions `json:"stream_options,omitempty"`
+	Tools         []Tool         `json:"tools,omitempty"`
+	ToolChoice    *ToolChoice    `json:"tool_choice,omitempty"`
+
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+
+	Temperature       *float64 `json:"temperature,omitempty"`
+	TopP              *float64 `json:"top_p,omitempty"`
+	MaxTokens         *int     `json:"max_tokens,omitempty"`
+	Stop              []string `json:"stop,omitempty"`
+	Seed              *int64   `json:"seed,omitempty"`
+	PresencePenalty   *float64 `json:"presence_penalty,omitempty"`
+	FrequencyPenalty  *float64 `json:"frequency_penalty,omitempty"`
+	N                 *int     `json:"n,omitempty"`
+	ParallelToolCalls *bool    `json:"parallel_tool_calls,omitempty"`
+	Logprobs          *bool    `json:"logprobs,omitempty"`
+	TopLogprobs       *int     `json:"top_logprobs,omitempty"`
+
+	// Extra is merged into the top level of the encoded body, for provider
+	// specific fields such as DashScope's enable_thinking.
+	Extra map[string]interface{} `json:"-"`
+}
+
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage,omitempty"`
 }
 
 type ChatCompletionResponse struct {
@@ -78,23 +115,25 @@ type ChatCompletionResponse struct {
 	Created int64  `json:"created,omitempty"`
 	Model   string `json:"model,omitempty"`
 
-	Choices []struct {
-		Index        int     `json:"index,omitempty"`
-		Message      Message `json:"message,omitempty"`
-		FinishReason string  `json:"finish_reason,omitempty"`
-	} `json:"choices,omitempty"`
+	Choices []Choice `json:"choices,omitempty"`
+	Usage   *Usage   `json:"usage,omitempty"`
 
-	Usage *struct {
-		PromptTokens     int `json:"prompt_tokens,omitempty"`
-		CompletionTokens int `json:"completion_tokens,omitempty"`
-		TotalTokens      int `json:"total_tokens,omitempty"`
-	} `json:"usage,omitempty"`
+	Error *apiErrorBody `json:"error,omitempty"`
+}
 
-	Error *struct {
-		Message string      `json:"message,omitempty"`
-		Type    string      `json:"type,omitempty"`
-		Code    interface{} `json:"code,omitempty"`
-	} `json:"error,omitempty"`
+type Choice struct {
+	Index        int     `json:"index,omitempty"`
+	Message      Message `json:"message,omitempty"`
+	FinishReason string  `json:"finish_reason,omitempty"`
+}
+
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens,omitempty"`
+	CompletionTokens int `json:"completion_tokens,omitempty"`
+	TotalTokens      int `json:"total_tokens,omitempty"`
+
+	PromptTokensDetails     *PromptTokensDetails     `json:"prompt_tokens_details,omitempty"`
+	CompletionTokensDetails *CompletionTokensDetails `json:"completion_tokens_details,omitempty"`
 }
 
 type InvokeResult struct {
@@ -106,33 +145,33 @@ type InvokeResult struct {
 	RawRequest  []byte
 	RawResponse []byte
 	Response    ChatCompletionResponse
+
+	Attempts []InvokeAttempt
 }
 
-func (c *Client) Invoke(ctx context.Context, req ChatCompletionRequest) (*InvokeResult, error) {
-	if strings.TrimSpace(c.Endpoint) == "" {
-		return nil, errors.New("empty endpoint")
-	}
-	if strings.TrimSpace(c.APIKey) == "" {
-		return nil, errors.New("empty api key")
+// failedInvoke is the partial result of a request that got no response: the
+// attempts (and how long they took) are still worth reporting.
+func (c *Client) failedInvoke(req ChatCompletionRequest, payload []byte, start time.Time, attempts []InvokeAttempt) *InvokeResult {
+	return &InvokeResult{
+		Endpoint:   c.Endpoint,
+		Duration:   time.Since(start),
+		Request:    req,
+		RawRequest: payload,
+		Attempts:   attempts,
 	}
+}
 
-	payload, err := json.Marshal(req)
+func (c *Client) Invoke(ctx context.Context, req ChatCompletionRequest) (*InvokeResult, error) {
+	payload, err := c.marshalRequest(req)
 	if err != nil {
-		return nil, fmt.Errorf("marshal request: %w", err)
+		return nil, err
 	}
 
 	start := time.Now()
 
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.Endpoint, bytes.NewReader(payload))
-	if err != nil {
-		return nil, fmt.Errorf("new request: %w", err)
-	}
-	httpReq.Header.Set("Authorization", "Bearer "+c.APIKey)
-	httpReq.Header.Set("Content-Type", "application/json")
-
-	resp, err := c.HTTPClient.Do(httpReq)
+	resp, attempts, err := c.postWithRetry(ctx, payload)
 	if err != nil {
-		return nil, fmt.Errorf("http request: %w", err)
+		return c.failedInvoke(req, payload, start, attempts), err
 	}
 	defer resp.Body.Close()
 
@@ -148,24 +187,56 @@ func (c *Client) Invoke(ctx context.Context, req ChatCompletionRequest) (*Invoke
 		Request:     req,
 		RawRequest:  payload,
 		RawResponse: bodyBytes,
+		Attempts:    attempts,
 	}
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		msg := strings.TrimSpace(string(bodyBytes))
-		if msg == "" {
-			msg = resp.Status
-		}
-		return out, fmt.Errorf("http %d: %s", resp.StatusCode, msg)
+	if err := checkStatus(resp, bodyBytes); err != nil {
+		return out, err
 	}
 
-	if err := json.Unmarshal(bodyBytes, &out.Response); err != nil {
-		return out, fmt.Errorf("decode json: %w", err)
+	if err := c.provider().DecodeResponse(bodyBytes, &out.Response); err != nil {
+		return out, err
 	}
 	if out.Response.Error != nil && strings.TrimSpace(out.Response.Error.Message) != "" {
-		return out, fmt.Errorf("api error: %s", strings.TrimSpace(out.Response.Error.Message))
+		return out, newBodyAPIError(resp, bodyBytes, out.Response.Error)
 	}
 	if len(out.Response.Choices) == 0 {
 		return out, errors.New("empty choices")
 	}
 	return out, nil
 }
+
+func (c *Client) marshalRequest(req ChatCompletionRequest) ([]byte, error) {
+	if strings.TrimSpace(c.Endpoint) == "" {
+		return nil, errors.New("empty endpoint")
+	}
+	if strings.TrimSpace(c.APIKey) == "" {
+		return nil, errors.New("empty api key")
+	}
+	payload, err := c.provider().EncodeRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+	return payload, nil
+}
+
+func (c *Client) post(ctx context.Context, payload []byte) (*http.Response, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.Endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("new request: %w", err)
+	}
+	c.provider().SetHeaders(httpReq.Header, c.APIKey)
+
+	resp, err := c.HTTPClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("http request: %w", err)
+	}
+	return resp, nil
+}
+
+func checkStatus(resp *http.Response, body []byte) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	return newAPIError(resp, body)
+}
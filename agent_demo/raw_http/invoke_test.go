@@ -49,10 +49,10 @@ func TestClientInvoke_Integration_HappyPath(t *testing.T) {
 			{Role: "system", Content: "You are a tool-calling assistant. You must call the provided tool to answer. Do not answer directly."},
 			{Role: "user", Content: "What's the current weather in San Francisco? Call get_current_weather with unit=celsius."},
 		},
-		Temperature: 0,
+		Temperature: Ptr(0.0),
 		Stream:      false,
 		Tools:       tools,
-		ToolChoice:  "required",
+		ToolChoice:  ToolChoiceRequired(),
 	})
 	if err != nil {
 		t.Fatalf("Invoke error: %v", err)
@@ -63,3 +63,62 @@ func TestClientInvoke_Integration_HappyPath(t *testing.T) {
 	}
 	t.Log(msg)
 }
+
+func TestClientInvoke_Offline_HappyPath(t *testing.T) {
+	srv := newFakeServer(t, FakeResponse{
+		Message: Message{Role: "assistant", Content: "hello"},
+		Usage:   &Usage{PromptTokens: 12, CompletionTokens: 3, TotalTokens: 15},
+	})
+
+	invoke, err := srv.APIClient().Invoke(context.Background(), ChatCompletionRequest{
+		Model:       "qwen-plus",
+		Messages:    []Message{{Role: "user", Content: "hi"}},
+		Temperature: Ptr(0.3),
+	})
+	if err != nil {
+		t.Fatalf("Invoke error: %v", err)
+	}
+	if got := invoke.Response.Choices[0].Message.Content; got != "hello" {
+		t.Fatalf("content = %q", got)
+	}
+	if invoke.StatusCode != 200 || invoke.Response.Usage == nil || invoke.Response.Usage.TotalTokens != 15 {
+		t.Fatalf("invoke = %+v", invoke)
+	}
+	if len(invoke.RawRequest) == 0 || len(invoke.RawResponse) == 0 {
+		t.Fatalf("raw request/response not recorded")
+	}
+
+	reqs := srv.Requests()
+	if len(reqs) != 1 {
+		t.Fatalf("requests = %d, want 1", len(reqs))
+	}
+	if got := reqs[0].Header.Get("Authorization"); got != "Bearer test-key" {
+		t.Fatalf("Authorization = %q", got)
+	}
+	if reqs[0].Req.Model != "qwen-plus" || reqs[0].Req.Temperature == nil || *reqs[0].Req.Temperature != 0.3 || reqs[0].Req.Messages[0].Content != "hi" {
+		t.Fatalf("request = %+v", reqs[0].Req)
+	}
+}
+
+func TestClientInvoke_Offline_Errors(t *testing.T) {
+	srv := newFakeServer(t,
+		fakeError(200, `{"error":{"message":"quota exhausted","code":"insufficient_quota"}}`),
+		fakeError(200, `{"choices":[]}`),
+		fakeError(200, `not json`),
+		FakeResponse{Message: Message{Content: "late"}, Latency: time.Second},
+	)
+	client := srv.APIClient()
+
+	for _, want := range []string{"api error", "empty choices", "decode json"} {
+		_, err := client.Invoke(context.Background(), ChatCompletionRequest{Model: "qwen-plus"})
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("err = %v, want %q", err, want)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := client.Invoke(ctx, ChatCompletionRequest{Model: "qwen-plus"}); err == nil {
+		t.Fatalf("expected timeout error")
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+var ErrToolLoop = errors.New("tool call loop detected")
+
+const defaultLoopMaxRepeats = 3
+
+type LoopAction int
+
+const (
+	// LoopWarnThenStop injects a corrective message on the first detection
+	// and stops the loop with ErrToolLoop on the next one.
+	LoopWarnThenStop LoopAction = iota
+	LoopWarn
+	LoopStop
+)
+
+// ToolLoop records one detected pattern. Pattern is "repeat" (the same call
+// with the same arguments Count times) or "oscillation" (the same sequence of
+// steps, Period steps long, twice in a row).
+type ToolLoop struct {
+	Step    int
+	Pattern string
+	Calls   []string
+	Count   int
+	Period  int
+	Stopped bool
+}
+
+func (l ToolLoop) String() string {
+	if l.Pattern == "oscillation" {
+		return fmt.Sprintf("oscillation with period %d: %s", l.Period, strings.Join(l.Calls, " -> "))
+	}
+	return fmt.Sprintf("%s repeated %d times", strings.Join(l.Calls, ", "), l.Count)
+}
+
+// WithLoopDetection sets how many identical calls count as a loop and what to
+// do about it. Detection is on by default (3 repeats within 5 steps,
+// LoopWarnThenStop); a repeat counts only within 2*maxRepeats-1 recent steps.
+func WithLoopDetection(maxRepeats int, action LoopAction) ReACTOption {
+	return func(c *reactConfig) {
+		c.loopMaxRepeats = maxRepeats
+		c.loopAction = action
+	}
+}
+
+func WithoutLoopDetection() ReACTOption {
+	return func(c *reactConfig) { c.loopMaxRepeats = -1 }
+}
+
+type loopDetector struct {
+	maxRepeats int
+	steps      []string
+	stepCalls  [][]string
+}
+
+func newLoopDetector(maxRepeats int) *loopDetector {
+	if maxRepeats < 0 {
+		return nil
+	}
+	if maxRepeats < 2 {
+		maxRepeats = defaultLoopMaxRepeats
+	}
+	return &loopDetector{maxRepeats: maxRepeats}
+}
+
+// window is how many recent steps a repeat must fall within: with the
+// default 3 repeats, the same call in 3 of the last 5 steps. A call that
+// legitimately recurs now and then over a long run never adds up.
+func (d *loopDetector) window() int { return 2*d.maxRepeats - 1 }
+
+// observe records one step's calls and reports a loop if this step completes
+// one. Repeats win over oscillation when both apply.
+func (d *loopDetector) observe(step int, calls []ToolCall) *ToolLoop {
+	if d == nil || len(calls) == 0 {
+		return nil
+	}
+	// Identical parallel calls within one step count once: a loop is about
+	// calls repeated across steps.
+	var keys []string
+	for _, tc := range calls {
+		if k := toolCallKey(tc); !slices.Contains(keys, k) {
+			keys = append(keys, k)
+		}
+	}
+	sorted := append([]string(nil), keys...)
+	sort.Strings(sorted)
+	d.steps = append(d.steps, strings.Join(sorted, "\n"))
+	d.stepCalls = append(d.stepCalls, keys)
+
+	recent := d.stepCalls[max(0, len(d.stepCalls)-d.window()):]
+	counts := map[string]int{}
+	for _, sc := range recent {
+		for _, k := range sc {
+			counts[k]++
+		}
+	}
+	var repeated []string
+	count := 0
+	for _, k := range keys {
+		if n := counts[k]; n >= d.maxRepeats && !slices.Contains(repeated, k) {
+			repeated = append(repeated, k)
+			count = max(count, n)
+		}
+	}
+
+	if len(repeated) > 0 {
+		return &ToolLoop{Step: step, Pattern: "repeat", Calls: repeated, Count: count}
+	}
+	n := len(d.steps)
+	for period := 2; period <= 3 && n >= 2*period; period++ {
+		distinct := false
+		match := true
+		for i := 0; i < period; i++ {
+			if d.steps[n-1-i] != d.steps[n-1-i-period] {
+				match = false
+				break
+			}
+			if d.steps[n-1-i] != d.steps[n-1] {
+				distinct = true
+			}
+		}
+		if match && distinct {
+			var cycle []string
+			for _, sc := range d.stepCalls[n-period:] {
+				cycle = append(cycle, strings.Join(sc, " + "))
+			}
+			return &ToolLoop{Step: step, Pattern: "oscillation", Calls: cycle, Count: 2, Period: period}
+		}
+	}
+	return nil
+}
+
+func toolCallKey(tc ToolCall) string {
+	args := strings.TrimSpace(tc.Function.Arguments)
+	if v, err := decodeJSONValue([]byte(args)); err == nil {
+		if b, err := json.Marshal(v); err == nil {
+			args = string(b)
+		}
+	}
+	if args == "" || args == "null" {
+		args = "{}"
+	}
+	return tc.Function.Name + " " + args
+}
+
+func loopCorrection(l *ToolLoop) string {
+	if l.Pattern == "oscillation" {
+		return fmt.Sprintf("[loop detected] Your last tool calls are cycling (%s) without making progress. "+
+			"Stop alternating between them: use the results you already have, try a different approach, or give your final answer.", l)
+	}
+	return fmt.Sprintf("[loop detected] You have made the same tool call %d times with identical arguments (%s) and the result will not change. "+
+		"Do not call it again: use the results you already have, try a different approach, or give your final answer.", l.Count, strings.Join(l.Calls, ", "))
+}
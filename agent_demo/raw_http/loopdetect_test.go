@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestLoopDetector(t *testing.T) {
+	d := newLoopDetector(3)
+	a := toolCall("1", "now", `{"timezone":"UTC"}`)
+	aSpaced := toolCall("2", "now", `{ "timezone" : "UTC" }`)
+	b := toolCall("3", "calculator", `{"expression":"1+1"}`)
+
+	if l := d.observe(1, []ToolCall{a}); l != nil {
+		t.Fatalf("step 1 = %+v", l)
+	}
+	if l := d.observe(2, []ToolCall{b}); l != nil {
+		t.Fatalf("step 2 = %+v", l)
+	}
+	if l := d.observe(3, []ToolCall{aSpaced}); l != nil {
+		t.Fatalf("step 3 = %+v", l)
+	}
+	l := d.observe(4, []ToolCall{b})
+	if l == nil || l.Pattern != "oscillation" || l.Period != 2 || l.Step != 4 {
+		t.Fatalf("step 4 = %+v", l)
+	}
+	if got := l.String(); got != `oscillation with period 2: now {"timezone":"UTC"} -> calculator {"expression":"1+1"}` {
+		t.Fatalf("string = %q", got)
+	}
+	l = d.observe(5, []ToolCall{a})
+	if l == nil || l.Pattern != "repeat" || l.Count != 3 || l.Calls[0] != `now {"timezone":"UTC"}` {
+		t.Fatalf("step 5 = %+v", l)
+	}
+
+	// The same call now and then over a long run is not a loop.
+	spread := newLoopDetector(3)
+	for step := 1; step <= 12; step++ {
+		calls := []ToolCall{toolCall("c", "calculator", fmt.Sprintf(`{"expression":"%d"}`, step))}
+		if step%3 == 1 {
+			calls = []ToolCall{toolCall("n", "now", `{}`)}
+		}
+		if l := spread.observe(step, calls); l != nil {
+			t.Fatalf("spread calls reported a loop at step %d: %+v", step, l)
+		}
+	}
+
+	// Identical parallel calls within one step are not a loop by themselves.
+	parallel := newLoopDetector(3)
+	same := []ToolCall{toolCall("p1", "now", `{}`), toolCall("p2", "now", `{}`), toolCall("p3", "now", `{}`)}
+	for step := 1; step <= 2; step++ {
+		if l := parallel.observe(step, same); l != nil {
+			t.Fatalf("parallel calls reported a loop at step %d: %+v", step, l)
+		}
+	}
+	if l := parallel.observe(3, same); l == nil || l.Pattern != "repeat" || l.Count != 3 {
+		t.Fatalf("step 3 = %+v", l)
+	}
+
+	if newLoopDetector(-1).observe(1, []ToolCall{a}) != nil {
+		t.Fatalf("disabled detector reported a loop")
+	}
+}
+
+func TestDoReACT_Offline_LoopCorrectedThenStopped(t *testing.T) {
+	same := func() FakeResponse { return fakeToolCalls(toolCall("c", "calculator", `{"expression":"1+1"}`)) }
+	srv := newFakeServer(t, same(), same(), same(), same(), fakeText("unreachable"))
+	tools, handlers := BuiltinTools()
+	rec := &eventRecorder{}
+
+	res, err := doReACT(context.Background(), srv.APIClient(), "qwen-plus", "sys", "go", tools, handlers, 0, 8, WithObserver(rec))
+	if !errors.Is(err, ErrToolLoop) {
+		t.Fatalf("err = %v, want ErrToolLoop", err)
+	}
+	if len(srv.Requests()) != 4 {
+		t.Fatalf("requests = %d, want 4", len(srv.Requests()))
+	}
+	if len(res.Loops) != 2 || res.Loops[0].Stopped || !res.Loops[1].Stopped || res.Loops[0].Step != 3 || res.Loops[1].Step != 4 {
+		t.Fatalf("loops = %+v", res.Loops)
+	}
+
+	sent := srv.Requests()[3].Req.Messages
+	last := sent[len(sent)-1]
+	if last.Role != "user" || !strings.HasPrefix(last.Content, "[loop detected] You have made the same tool call 3 times") {
+		t.Fatalf("corrective message = %+v", last)
+	}
+	tail := res.Messages[len(res.Messages)-1]
+	if tail.Role != "tool" || tail.Content != "tool call skipped: loop detected" {
+		t.Fatalf("history tail = %+v", tail)
+	}
+	if !strings.Contains(rec.types(), "loop_detected") {
+		t.Fatalf("events = %s", rec.types())
+	}
+	if out := RenderReACTResult(res); !strings.Contains(out, "!! loop detected at step 4 (stopped)") {
+		t.Fatalf("render:\n%s", out)
+	}
+}
+
+func TestDoReACT_Offline_LoopDetectionOptions(t *testing.T) {
+	same := func() FakeResponse { return fakeToolCalls(toolCall("c", "calculator", `{"expression":"1+1"}`)) }
+	tools, handlers := BuiltinTools()
+
+	srv := newFakeServer(t, same(), same())
+	res, err := doReACT(context.Background(), srv.APIClient(), "qwen-plus", "sys", "go", tools, handlers, 0, 8, WithLoopDetection(2, LoopStop))
+	if !errors.Is(err, ErrToolLoop) || len(res.Loops) != 1 || !res.Loops[0].Stopped {
+		t.Fatalf("LoopStop: err = %v, loops = %+v", err, res.Loops)
+	}
+
+	srv = newFakeServer(t, same(), same(), same(), fakeText("ok"))
+	res, err = doReACT(context.Background(), srv.APIClient(), "qwen-plus", "sys", "go", tools, handlers, 0, 8, WithoutLoopDetection())
+	if err != nil || res.Final != "ok" || len(res.Loops) != 0 {
+		t.Fatalf("disabled: err = %v, res = %+v", err, res)
+	}
+}
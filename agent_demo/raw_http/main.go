@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("raw_http", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	var (
+		prompt   = fs.String("prompt", "", "user prompt")
+		provider = fs.String("provider", "openai", "API protocol: openai (DashScope compatible-mode) or anthropic")
+		model    = fs.String("model", "", "model name (default qwen-plus, or claude-sonnet-4-5 with -provider anthropic)")
+		endpoint = fs.String("endpoint", "", "API endpoint (default depends on -provider)")
+		system   = fs.String("system", "You are a helpful assistant.", "system prompt")
+		temp     = fs.Float64("temp", 0.7, "temperature")
+		topP     = fs.Float64("top-p", 0, "nucleus sampling top_p (sent only when set)")
+		maxTok   = fs.Int("max-tokens", 0, "max_tokens for each response (sent only when set)")
+		seed     = fs.Int64("seed", 0, "sampling seed (sent only when set)")
+		thinking = fs.Bool("enable-thinking", false, "send DashScope enable_thinking (sent only when set)")
+		timeout  = fs.Duration("timeout", 60*time.Second, "request timeout")
+		react    = fs.Bool("react", false, "enable the ReACT loop (handles tool_calls automatically)")
+		maxSteps = fs.Int("max-steps", 8, "max ReACT steps")
+		choice   = fs.String("tool-choice", "", `tool_choice per ReACT step as first[,rest[,last]], each auto|none|required|<tool name>, e.g. "required,auto,none"; a single required or tool name only forces step 1`)
+		force    = fs.Bool("force-final", false, "when -max-steps runs out, ask the model for a final answer without tools instead of failing")
+		toolTO   = fs.Duration("tool-timeout", 30*time.Second, "per tool call timeout in the ReACT loop (0 = none)")
+		parallel = fs.Int("max-parallel-tools", 4, "max concurrent tool calls per ReACT step (0 = unlimited)")
+		record   = fs.String("record", "", "record HTTP traffic to this cassette file")
+		replay   = fs.String("replay", "", "replay HTTP traffic from this cassette file (no network)")
+		live     = fs.Bool("live", false, "print ReACT loop events to stderr as they happen")
+		allowed  = fs.String("allowed-tools", "", `tools that run without asking, e.g. "now,calculator,mcp__doris__*,run_sql(sql=SELECT:*)"`)
+		blocked  = fs.String("disallowed-tools", "", "tools that are always denied (same syntax as -allowed-tools)")
+		approve  = fs.Bool("approve", false, "ask on the terminal before running tools not in -allowed-tools")
+		mcpSpecs []string
+		images   []ContentPart
+	)
+	fs.Func("mcp", "MCP server for -react as name=command args... or name=http(s)://url (repeatable; connecting and listing tools is bounded by -timeout)", func(v string) error {
+		if _, _, ok := strings.Cut(v, "="); !ok {
+			return errors.New("want name=command or name=url")
+		}
+		mcpSpecs = append(mcpSpecs, v)
+		return nil
+	})
+	fs.Func("image", "attach a local image file to the prompt (repeatable; needs a vision model such as qwen-vl-plus)", func(v string) error {
+		part, err := ImageFilePart(v)
+		if err != nil {
+			return err
+		}
+		images = append(images, part)
+		return nil
+	})
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if strings.TrimSpace(*prompt) == "" {
+		fmt.Fprintln(stderr, "missing -prompt")
+		fs.Usage()
+		return 2
+	}
+
+	if *record != "" && *replay != "" {
+		fmt.Fprintln(stderr, "-record and -replay are mutually exclusive")
+		return 2
+	}
+
+	sess, err := newCLISession(*provider, *endpoint, *model, *timeout, *replay != "")
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	switch {
+	case *record != "":
+		err = sess.client.UseCassette(CassetteRecord, *record)
+	case *replay != "":
+		err = sess.client.UseCassette(CassetteReplay, *replay)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	sess.SetSystemPrompt(*system)
+	sess.SetTemperature(*temp)
+	var sampling SamplingParams
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "top-p":
+			sampling.TopP = topP
+		case "max-tokens":
+			sampling.MaxTokens = maxTok
+		case "seed":
+			sampling.Seed = seed
+		case "enable-thinking":
+			sampling.Extra = map[string]interface{}{"enable_thinking": *thinking}
+		}
+	})
+	sess.SetSampling(sampling)
+	sess.SetMaxSteps(*maxSteps)
+
+	ctx := context.Background()
+	reactOpts := []ReACTOption{WithSampling(sampling)}
+	var obs ReACTObserver
+	if *live {
+		obs = NewLiveRenderer(stderr)
+		reactOpts = append(reactOpts, WithObserver(obs))
+	}
+	if *choice != "" {
+		strategy, err := ParseToolChoiceStrategy(*choice)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+		reactOpts = append(reactOpts, WithToolChoice(strategy))
+	}
+	if *force {
+		reactOpts = append(reactOpts, WithForcedFinalAnswer(""))
+	}
+	if *allowed != "" || *blocked != "" || *approve {
+		perms := &ToolPermissions{Allow: ParseToolRules(*allowed), Deny: ParseToolRules(*blocked)}
+		switch {
+		case *approve:
+			perms.Ask = NewTerminalApprover(os.Stdin, stderr)
+		case *allowed == "":
+			perms.Allow = []string{"*"}
+		}
+		reactOpts = append(reactOpts, WithApprover(perms))
+	}
+
+	if *react {
+		reg, err := NewToolRegistryFrom(BuiltinTools())
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		for _, spec := range mcpSpecs {
+			mc, err := connectMCPSpec(ctx, spec, *timeout, reg, stderr)
+			if mc != nil {
+				defer mc.Close()
+			}
+			if err != nil {
+				fmt.Fprintln(stderr, err)
+				return 1
+			}
+		}
+		history := []Message{{Role: "system", Content: *system}, UserMessage(*prompt, images...)}
+		res, err := doReACTWithHistory(ctx, sess.client, sess.model, history, reg.Tools(), reg.Handlers(), sess.temperature, sess.maxSteps,
+			append(reactOpts, WithToolTimeout(*toolTO), WithMaxParallelTools(*parallel))...)
+		fmt.Fprint(stdout, RenderReACTResult(res))
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		return 0
+	}
+
+	sess.SetObserver(obs)
+	if _, err := sess.ChatParts(ctx, *prompt, images...); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	msgs := sess.Messages()
+	fmt.Fprint(stdout, RenderReACTResult(&ReACTResult{
+		BaseMessagesLen: len(msgs) - 1,
+		Messages:        msgs,
+	}))
+	return 0
+}
+
+// connectMCPSpec connects to one -mcp server and registers its tools, giving
+// the handshake and tools/list together at most timeout (0 = no limit) so a
+// server that never answers cannot hang the CLI.
+func connectMCPSpec(ctx context.Context, spec string, timeout time.Duration, reg *ToolRegistry, stderr io.Writer) (*MCPClient, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	name, target, _ := strings.Cut(spec, "=")
+	var (
+		mc  *MCPClient
+		err error
+	)
+	if strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://") {
+		mc, err = ConnectMCPHTTP(ctx, name, target, nil)
+	} else {
+		argv := strings.Fields(target)
+		if len(argv) == 0 {
+			return nil, fmt.Errorf("mcp %s: empty command", name)
+		}
+		cmd := exec.Command(argv[0], argv[1:]...)
+		cmd.Stderr = stderr
+		mc, err = ConnectMCPStdio(ctx, name, cmd)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return mc, mc.RegisterTools(ctx, reg)
+}
+
+func newCLISession(provider, endpoint, model string, timeout time.Duration, replay bool) (*Session, error) {
+	spec, err := lookupProvider(provider)
+	if err != nil {
+		return nil, err
+	}
+	apiKey := strings.TrimSpace(os.Getenv(spec.keyEnv))
+	if apiKey == "" {
+		if !replay {
+			return nil, fmt.Errorf("missing API key: set %s", spec.keyEnv)
+		}
+		apiKey = "replay"
+	}
+	if strings.TrimSpace(model) == "" {
+		model = spec.defaultModel
+	}
+	return NewSessionWithClient(spec.newClient(endpoint, apiKey, timeout), model), nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRun_MissingPrompt(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run(nil, &stdout, &stderr); code != 2 {
+		t.Fatalf("exit code = %d, want 2", code)
+	}
+	if !strings.Contains(stderr.String(), "missing -prompt") {
+		t.Fatalf("stderr = %q, want missing -prompt", stderr.String())
+	}
+}
+
+func TestRun_MissingAPIKey(t *testing.T) {
+	t.Setenv("QWEN_API_KEY", "")
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-prompt", "hi"}, &stdout, &stderr); code != 1 {
+		t.Fatalf("exit code = %d, want 1", code)
+	}
+	if !strings.Contains(stderr.String(), "QWEN_API_KEY") {
+		t.Fatalf("stderr = %q, want QWEN_API_KEY hint", stderr.String())
+	}
+}
+
+func TestRun_PrintsTranscript(t *testing.T) {
+	t.Setenv("QWEN_API_KEY", "test-key")
+	srv := newFakeServer(t, fakeText("hello there"))
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-endpoint", srv.URL, "-prompt", "hi"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code = %d, stderr = %q", code, stderr.String())
+	}
+	out := stdout.String()
+	for _, want := range []string{"01 system:", "02 user:\n  hi", "03 assistant:\n  hello there"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("stdout missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestRun_ReACTPrintsTranscript(t *testing.T) {
+	t.Setenv("QWEN_API_KEY", "test-key")
+	srv := newFakeServer(t,
+		fakeToolCalls(toolCall("call_1", "calculator", `{"expression":"6*7"}`)),
+		fakeText("42"),
+	)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-react", "-endpoint", srv.URL, "-prompt", "6*7?"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("exit code = %d, stderr = %q", code, stderr.String())
+	}
+	out := stdout.String()
+	for _, want := range []string{"- calculator (id=call_1)", "tool[call_1]:\n  42", "assistant#2 (finish=stop"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("stdout missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestRun_InvokeErrorExitsNonZero(t *testing.T) {
+	t.Setenv("QWEN_API_KEY", "test-key")
+	body := `{"error":{"message":"upstream exploded","type":"internal_error"}}`
+	// The CLI uses the default retry policy, so serve every attempt.
+	srv := newFakeServer(t, fakeError(500, body), fakeError(500, body), fakeError(500, body))
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"-endpoint", srv.URL, "-prompt", "hi"}, &stdout, &stderr); code != 1 {
+		t.Fatalf("exit code = %d, want 1", code)
+	}
+	if !strings.Contains(stderr.String(), "http 500") || !strings.Contains(stderr.String(), "upstream exploded") {
+		t.Fatalf("stderr = %q", stderr.String())
+	}
+	if stdout.Len() != 0 {
+		t.Fatalf("stdout = %q", stdout.String())
+	}
+}
+
+func TestConnectMCPSpec_TimesOutOnSilentServer(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release // accept the request, never answer initialize
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	reg := NewToolRegistry()
+	start := time.Now()
+	_, err := connectMCPSpec(context.Background(), "silent="+srv.URL, 50*time.Millisecond, reg, io.Discard)
+	if !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > 2*time.Second {
+		t.Fatalf("err = %v after %s", err, time.Since(start))
+	}
+}
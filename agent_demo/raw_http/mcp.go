@@ -0,0 +1,550 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const MCPProtocolVersion = "2025-03-26"
+
+type MCPError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *MCPError) Error() string {
+	return fmt.Sprintf("mcp error %d: %s", e.Code, e.Message)
+}
+
+type mcpMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *MCPError       `json:"error,omitempty"`
+}
+
+// mcpTransport delivers one JSON-RPC message. For requests it returns the
+// matching response; for notifications (no ID) it returns nil.
+type mcpTransport interface {
+	roundTrip(ctx context.Context, msg *mcpMessage) (*mcpMessage, error)
+	Close() error
+}
+
+type MCPImplementation struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type MCPTool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	InputSchema map[string]interface{} `json:"inputSchema,omitempty"`
+}
+
+type MCPContent struct {
+	Type     string          `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	Data     string          `json:"data,omitempty"`
+	MimeType string          `json:"mimeType,omitempty"`
+	Resource json.RawMessage `json:"resource,omitempty"`
+}
+
+type MCPToolResult struct {
+	Content           []MCPContent    `json:"content"`
+	StructuredContent json.RawMessage `json:"structuredContent,omitempty"`
+	IsError           bool            `json:"isError,omitempty"`
+}
+
+// String flattens the result into tool message content: text parts are
+// joined by newlines, binary parts become short placeholders.
+func (r *MCPToolResult) String() string {
+	var parts []string
+	for _, c := range r.Content {
+		switch c.Type {
+		case "text":
+			parts = append(parts, c.Text)
+		case "image", "audio":
+			parts = append(parts, fmt.Sprintf("[%s %s, %d bytes base64]", c.Type, c.MimeType, len(c.Data)))
+		case "resource":
+			parts = append(parts, string(c.Resource))
+		default:
+			parts = append(parts, fmt.Sprintf("[%s content]", c.Type))
+		}
+	}
+	if len(parts) == 0 && len(r.StructuredContent) > 0 {
+		return string(r.StructuredContent)
+	}
+	return strings.Join(parts, "\n")
+}
+
+type MCPClient struct {
+	name            string
+	transport       mcpTransport
+	nextID          atomic.Int64
+	ServerInfo      MCPImplementation
+	ProtocolVersion string
+	Instructions    string
+}
+
+// ConnectMCPStdio starts cmd and speaks newline-delimited JSON-RPC over its
+// stdin/stdout. cmd.Stderr is left as configured by the caller.
+func ConnectMCPStdio(ctx context.Context, name string, cmd *exec.Cmd) (*MCPClient, error) {
+	t, err := newMCPStdioTransport(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return connectMCP(ctx, name, t)
+}
+
+// mcpHTTPTimeout bounds a single MCP HTTP exchange, including a streamed
+// response, as a backstop for callers whose ctx has no deadline; per-call
+// limits such as -tool-timeout come from ctx.
+const mcpHTTPTimeout = 5 * time.Minute
+
+// ConnectMCPHTTP talks to a streamable HTTP MCP endpoint. header is sent with
+// every request (e.g. Authorization).
+func ConnectMCPHTTP(ctx context.Context, name, url string, header http.Header) (*MCPClient, error) {
+	return connectMCP(ctx, name, &mcpHTTPTransport{
+		url:    url,
+		header: header,
+		client: &http.Client{Timeout: mcpHTTPTimeout},
+	})
+}
+
+func connectMCP(ctx context.Context, name string, t mcpTransport) (*MCPClient, error) {
+	c := &MCPClient{name: name, transport: t}
+	if err := c.initialize(ctx); err != nil {
+		t.Close()
+		return nil, fmt.Errorf("mcp %s: initialize: %w", name, err)
+	}
+	return c, nil
+}
+
+func (c *MCPClient) Name() string { return c.name }
+
+func (c *MCPClient) Close() error { return c.transport.Close() }
+
+func (c *MCPClient) initialize(ctx context.Context) error {
+	var res struct {
+		ProtocolVersion string            `json:"protocolVersion"`
+		ServerInfo      MCPImplementation `json:"serverInfo"`
+		Instructions    string            `json:"instructions"`
+	}
+	err := c.call(ctx, "initialize", map[string]interface{}{
+		"protocolVersion": MCPProtocolVersion,
+		"capabilities":    map[string]interface{}{},
+		"clientInfo":      MCPImplementation{Name: "raw_http", Version: "0.1.0"},
+	}, &res)
+	if err != nil {
+		return err
+	}
+	c.ProtocolVersion = res.ProtocolVersion
+	c.ServerInfo = res.ServerInfo
+	c.Instructions = res.Instructions
+	return c.notify(ctx, "notifications/initialized", nil)
+}
+
+func (c *MCPClient) call(ctx context.Context, method string, params, out interface{}) error {
+	msg, err := newMCPMessage(method, params)
+	if err != nil {
+		return err
+	}
+	msg.ID = json.RawMessage(fmt.Sprint(c.nextID.Add(1)))
+	resp, err := c.transport.roundTrip(ctx, msg)
+	if err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(resp.Result, out); err != nil {
+		return fmt.Errorf("decode %s result: %w", method, err)
+	}
+	return nil
+}
+
+func (c *MCPClient) notify(ctx context.Context, method string, params interface{}) error {
+	msg, err := newMCPMessage(method, params)
+	if err != nil {
+		return err
+	}
+	_, err = c.transport.roundTrip(ctx, msg)
+	return err
+}
+
+func newMCPMessage(method string, params interface{}) (*mcpMessage, error) {
+	msg := &mcpMessage{JSONRPC: "2.0", Method: method}
+	if params != nil {
+		b, err := json.Marshal(params)
+		if err != nil {
+			return nil, fmt.Errorf("encode %s params: %w", method, err)
+		}
+		msg.Params = b
+	}
+	return msg, nil
+}
+
+func (c *MCPClient) ListTools(ctx context.Context) ([]MCPTool, error) {
+	var out []MCPTool
+	cursor := ""
+	for {
+		params := map[string]interface{}{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		var page struct {
+			Tools      []MCPTool `json:"tools"`
+			NextCursor string    `json:"nextCursor"`
+		}
+		if err := c.call(ctx, "tools/list", params, &page); err != nil {
+			return nil, fmt.Errorf("mcp %s: tools/list: %w", c.name, err)
+		}
+		out = append(out, page.Tools...)
+		if page.NextCursor == "" {
+			return out, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+func (c *MCPClient) CallTool(ctx context.Context, name string, args json.RawMessage) (*MCPToolResult, error) {
+	if len(bytes.TrimSpace(args)) == 0 {
+		args = json.RawMessage("{}")
+	}
+	var res MCPToolResult
+	err := c.call(ctx, "tools/call", map[string]interface{}{
+		"name":      name,
+		"arguments": args,
+	}, &res)
+	if err != nil {
+		return nil, fmt.Errorf("mcp %s: tools/call %s: %w", c.name, name, err)
+	}
+	return &res, nil
+}
+
+// Namespace is the prefix the server's tools get in a ToolRegistry,
+// e.g. "mcp__doris".
+func (c *MCPClient) Namespace() string {
+	return NamespacedToolName("mcp", sanitizeToolName(c.name))
+}
+
+// Tools lists the server's tools as Tool definitions plus handlers that
+// dispatch through tools/call. Names are un-prefixed; use RegisterTools to
+// add them to a registry under Namespace().
+func (c *MCPClient) Tools(ctx context.Context) ([]Tool, map[string]ToolHandler, error) {
+	listed, err := c.ListTools(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	tools := make([]Tool, 0, len(listed))
+	handlers := make(map[string]ToolHandler, len(listed))
+	for _, t := range listed {
+		remote := t.Name
+		local := sanitizeToolName(remote)
+		params := t.InputSchema
+		if params == nil {
+			params = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+		}
+		tools = append(tools, Tool{
+			Type: "function",
+			Function: ToolFunction{
+				Name:        local,
+				Description: t.Description,
+				Parameters:  params,
+			},
+		})
+		handlers[local] = func(ctx context.Context, args json.RawMessage) (string, error) {
+			res, err := c.CallTool(ctx, remote, args)
+			if err != nil {
+				return "", err
+			}
+			if res.IsError {
+				return "", errors.New(res.String())
+			}
+			return res.String(), nil
+		}
+	}
+	return tools, handlers, nil
+}
+
+func (c *MCPClient) RegisterTools(ctx context.Context, reg *ToolRegistry) error {
+	tools, handlers, err := c.Tools(ctx)
+	if err != nil {
+		return err
+	}
+	return reg.RegisterNamespace(c.Namespace(), tools, handlers)
+}
+
+func sanitizeToolName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r == '-' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+type mcpStdioTransport struct {
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	pending map[string]chan *mcpMessage
+	done    chan struct{}
+	readErr error
+
+	closeOnce sync.Once
+}
+
+func newMCPStdioTransport(cmd *exec.Cmd) (*mcpStdioTransport, error) {
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start mcp server: %w", err)
+	}
+	t := &mcpStdioTransport{
+		cmd:     cmd,
+		stdin:   stdin,
+		pending: map[string]chan *mcpMessage{},
+		done:    make(chan struct{}),
+	}
+	go t.readLoop(stdout)
+	return t, nil
+}
+
+func (t *mcpStdioTransport) readLoop(r io.Reader) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		line := bytes.TrimSpace(sc.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var msg mcpMessage
+		if err := json.Unmarshal(line, &msg); err != nil {
+			continue
+		}
+		switch {
+		case msg.Method != "" && msg.ID != nil:
+			t.answerServerRequest(&msg)
+		case msg.Method != "":
+			// Server notifications (logging, progress, list_changed) are ignored.
+		default:
+			t.mu.Lock()
+			ch, ok := t.pending[string(msg.ID)]
+			delete(t.pending, string(msg.ID))
+			t.mu.Unlock()
+			if ok {
+				ch <- &msg
+			}
+		}
+	}
+	t.mu.Lock()
+	t.readErr = sc.Err()
+	if t.readErr == nil {
+		t.readErr = errors.New("mcp server closed its output")
+	}
+	t.mu.Unlock()
+	close(t.done)
+}
+
+// answerServerRequest replies to requests the server sends us. Only ping is
+// supported; the client advertises no other capabilities.
+func (t *mcpStdioTransport) answerServerRequest(req *mcpMessage) {
+	resp := &mcpMessage{JSONRPC: "2.0", ID: req.ID}
+	if req.Method == "ping" {
+		resp.Result = json.RawMessage("{}")
+	} else {
+		resp.Error = &MCPError{Code: -32601, Message: "method not found: " + req.Method}
+	}
+	t.write(resp)
+}
+
+func (t *mcpStdioTransport) write(msg *mcpMessage) error {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	_, err = t.stdin.Write(append(b, '\n'))
+	return err
+}
+
+func (t *mcpStdioTransport) roundTrip(ctx context.Context, msg *mcpMessage) (*mcpMessage, error) {
+	if msg.ID == nil {
+		return nil, t.write(msg)
+	}
+	id := string(msg.ID)
+	ch := make(chan *mcpMessage, 1)
+	t.mu.Lock()
+	t.pending[id] = ch
+	t.mu.Unlock()
+	defer func() {
+		t.mu.Lock()
+		delete(t.pending, id)
+		t.mu.Unlock()
+	}()
+
+	if err := t.write(msg); err != nil {
+		return nil, fmt.Errorf("write to mcp server: %w", err)
+	}
+	select {
+	case resp := <-ch:
+		return resp, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-t.done:
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		return nil, t.readErr
+	}
+}
+
+// Close closes the server's stdin and waits briefly for it to exit before
+// killing it.
+func (t *mcpStdioTransport) Close() error {
+	var err error
+	t.closeOnce.Do(func() {
+		t.stdin.Close()
+		exited := make(chan error, 1)
+		go func() { exited <- t.cmd.Wait() }()
+		select {
+		case err = <-exited:
+		case <-time.After(2 * time.Second):
+			t.cmd.Process.Kill()
+			<-exited
+		}
+	})
+	return err
+}
+
+type mcpHTTPTransport struct {
+	url    string
+	header http.Header
+	client *http.Client
+
+	mu        sync.Mutex
+	sessionID string
+}
+
+var errMCPResponseFound = errors.New("mcp response found")
+
+func (t *mcpHTTPTransport) roundTrip(ctx context.Context, msg *mcpMessage) (*mcpMessage, error) {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	t.setHeaders(req)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if id := resp.Header.Get("Mcp-Session-Id"); id != "" {
+		t.mu.Lock()
+		t.sessionID = id
+		t.mu.Unlock()
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("mcp http %d: %s", resp.StatusCode, strings.TrimSpace(string(b)))
+	}
+	if msg.ID == nil {
+		io.Copy(io.Discard, resp.Body)
+		return nil, nil
+	}
+
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		var found *mcpMessage
+		err := readSSE(resp.Body, func(data []byte) error {
+			var m mcpMessage
+			if json.Unmarshal(data, &m) != nil || m.Method != "" || !bytes.Equal(m.ID, msg.ID) {
+				return nil
+			}
+			found = &m
+			return errMCPResponseFound
+		})
+		if found != nil {
+			return found, nil
+		}
+		if err == nil {
+			err = errors.New("event stream ended without a response")
+		}
+		return nil, err
+	}
+
+	var out mcpMessage
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("decode mcp response: %w", err)
+	}
+	return &out, nil
+}
+
+func (t *mcpHTTPTransport) setHeaders(req *http.Request) {
+	for k, vs := range t.header {
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+	t.mu.Lock()
+	if t.sessionID != "" {
+		req.Header.Set("Mcp-Session-Id", t.sessionID)
+	}
+	t.mu.Unlock()
+}
+
+// Close ends the server-side session, if one was issued.
+func (t *mcpHTTPTransport) Close() error {
+	t.mu.Lock()
+	id := t.sessionID
+	t.mu.Unlock()
+	if id == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, t.url, nil)
+	if err != nil {
+		return err
+	}
+	t.setHeaders(req)
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// The test binary doubles as a stdio MCP server when re-executed with
+// RAW_HTTP_MCP_TEST_SERVER=1.
+func TestMain(m *testing.M) {
+	if os.Getenv("RAW_HTTP_MCP_TEST_SERVER") == "1" {
+		runTestMCPStdioServer()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+type testMCPServer struct {
+	mu    sync.Mutex
+	pongs int
+}
+
+func (s *testMCPServer) handle(msg *mcpMessage) *mcpMessage {
+	if msg.ID == nil {
+		return nil
+	}
+	resp := &mcpMessage{JSONRPC: "2.0", ID: msg.ID}
+	result := func(v interface{}) *mcpMessage {
+		resp.Result, _ = json.Marshal(v)
+		return resp
+	}
+	switch msg.Method {
+	case "initialize":
+		return result(map[string]interface{}{
+			"protocolVersion": MCPProtocolVersion,
+			"capabilities":    map[string]interface{}{"tools": map[string]interface{}{}},
+			"serverInfo":      MCPImplementation{Name: "test-mcp", Version: "1.0"},
+		})
+	case "tools/list":
+		var p struct{ Cursor string }
+		json.Unmarshal(msg.Params, &p)
+		if p.Cursor == "" {
+			return result(map[string]interface{}{
+				"tools": []MCPTool{
+					{Name: "add", Description: "Add two numbers.", InputSchema: map[string]interface{}{
+						"type":       "object",
+						"properties": map[string]interface{}{"a": map[string]interface{}{"type": "number"}, "b": map[string]interface{}{"type": "number"}},
+						"required":   []string{"a", "b"},
+					}},
+					{Name: "get.table-list"},
+				},
+				"nextCursor": "page2",
+			})
+		}
+		return result(map[string]interface{}{"tools": []MCPTool{{Name: "fail"}, {Name: "pongs"}}})
+	case "tools/call":
+		var p struct {
+			Name      string
+			Arguments map[string]float64
+		}
+		json.Unmarshal(msg.Params, &p)
+		text := func(s string, isErr bool) *mcpMessage {
+			return result(MCPToolResult{Content: []MCPContent{{Type: "text", Text: s}}, IsError: isErr})
+		}
+		switch p.Name {
+		case "add":
+			return text(fmt.Sprint(p.Arguments["a"]+p.Arguments["b"]), false)
+		case "get.table-list":
+			return result(MCPToolResult{Content: []MCPContent{
+				{Type: "text", Text: "orders\nusers"},
+				{Type: "image", MimeType: "image/png", Data: "iVBORw0KGgo="},
+			}})
+		case "fail":
+			return text("database unavailable", true)
+		case "pongs":
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			return text(fmt.Sprint(s.pongs), false)
+		}
+		resp.Error = &MCPError{Code: -32602, Message: "unknown tool " + p.Name}
+		return resp
+	}
+	resp.Error = &MCPError{Code: -32601, Message: "method not found"}
+	return resp
+}
+
+func runTestMCPStdioServer() {
+	srv := &testMCPServer{}
+	enc := json.NewEncoder(os.Stdout)
+	sc := bufio.NewScanner(os.Stdin)
+	for sc.Scan() {
+		var msg mcpMessage
+		if err := json.Unmarshal(sc.Bytes(), &msg); err != nil {
+			continue
+		}
+		if msg.Method == "" {
+			if string(msg.ID) == `"srv-ping"` && msg.Error == nil {
+				srv.mu.Lock()
+				srv.pongs++
+				srv.mu.Unlock()
+			}
+			continue
+		}
+		if msg.Method == "tools/list" {
+			enc.Encode(mcpMessage{JSONRPC: "2.0", Method: "notifications/message", Params: json.RawMessage(`{"level":"info","data":"listing"}`)})
+			enc.Encode(mcpMessage{JSONRPC: "2.0", ID: json.RawMessage(`"srv-ping"`), Method: "ping"})
+		}
+		if resp := srv.handle(&msg); resp != nil {
+			enc.Encode(resp)
+		}
+	}
+}
+
+func startTestMCPStdio(t *testing.T) *MCPClient {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	cmd.Env = append(os.Environ(), "RAW_HTTP_MCP_TEST_SERVER=1")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	c, err := ConnectMCPStdio(ctx, "test", cmd)
+	if err != nil {
+		t.Fatalf("ConnectMCPStdio: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func checkTestMCPTools(t *testing.T, c *MCPClient) {
+	t.Helper()
+	ctx := context.Background()
+	if c.ServerInfo.Name != "test-mcp" || c.ProtocolVersion != MCPProtocolVersion {
+		t.Fatalf("server info = %+v, version = %q", c.ServerInfo, c.ProtocolVersion)
+	}
+
+	reg := NewToolRegistry()
+	if err := c.RegisterTools(ctx, reg); err != nil {
+		t.Fatalf("RegisterTools: %v", err)
+	}
+	want := "mcp__test__add,mcp__test__get_table-list,mcp__test__fail,mcp__test__pongs"
+	if got := strings.Join(reg.Names(), ","); got != want {
+		t.Fatalf("names = %s", got)
+	}
+	add, _, _ := reg.Lookup("mcp__test__add")
+	if add.Function.Description != "Add two numbers." || add.Function.Parameters["required"] == nil {
+		t.Fatalf("add tool = %+v", add)
+	}
+
+	h := reg.Handlers()
+	if out, err := h["mcp__test__add"](ctx, json.RawMessage(`{"a":2,"b":40}`)); err != nil || out != "42" {
+		t.Fatalf("add = %q, %v", out, err)
+	}
+	if out, err := h["mcp__test__get_table-list"](ctx, nil); err != nil || out != "orders\nusers\n[image image/png, 12 bytes base64]" {
+		t.Fatalf("table list = %q, %v", out, err)
+	}
+	if _, err := h["mcp__test__fail"](ctx, nil); err == nil || err.Error() != "database unavailable" {
+		t.Fatalf("fail err = %v", err)
+	}
+	if _, err := c.CallTool(ctx, "missing", nil); err == nil || !strings.Contains(err.Error(), "mcp error -32602: unknown tool missing") {
+		t.Fatalf("unknown tool err = %v", err)
+	}
+}
+
+func TestMCPClient_Stdio(t *testing.T) {
+	c := startTestMCPStdio(t)
+	checkTestMCPTools(t, c)
+
+	res, err := c.CallTool(context.Background(), "pongs", nil)
+	if err != nil || res.String() == "0" {
+		t.Fatalf("server ping was not answered: %v %v", res, err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, err := c.ListTools(context.Background()); err == nil {
+		t.Fatalf("expected error after close")
+	}
+}
+
+func TestMCPClient_StreamableHTTP(t *testing.T) {
+	mcp := &testMCPServer{}
+	var (
+		mu      sync.Mutex
+		deleted bool
+		auth    []string
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		auth = append(auth, r.Header.Get("Authorization"))
+		mu.Unlock()
+		if r.Method == http.MethodDelete {
+			mu.Lock()
+			deleted = r.Header.Get("Mcp-Session-Id") == "sess-1"
+			mu.Unlock()
+			return
+		}
+		var msg mcpMessage
+		json.NewDecoder(r.Body).Decode(&msg)
+		if msg.Method == "initialize" {
+			w.Header().Set("Mcp-Session-Id", "sess-1")
+		} else if r.Header.Get("Mcp-Session-Id") != "sess-1" {
+			http.Error(w, "missing session", http.StatusBadRequest)
+			return
+		}
+		resp := mcp.handle(&msg)
+		if resp == nil {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		if msg.Method == "tools/call" {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprintf(w, "event: message\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/progress\",\"params\":{}}\n\n")
+			b, _ := json.Marshal(resp)
+			fmt.Fprintf(w, "event: message\ndata: %s\n\n", b)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer ts.Close()
+
+	c, err := ConnectMCPHTTP(context.Background(), "test", ts.URL, http.Header{"Authorization": {"Bearer t"}})
+	if err != nil {
+		t.Fatalf("ConnectMCPHTTP: %v", err)
+	}
+	checkTestMCPTools(t, c)
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !deleted {
+		t.Fatalf("session was not deleted on close")
+	}
+	for _, a := range auth {
+		if a != "Bearer t" {
+			t.Fatalf("authorization header = %q", a)
+		}
+	}
+}
+
+func TestDoReACT_Offline_MCPTools(t *testing.T) {
+	c := startTestMCPStdio(t)
+	reg := NewToolRegistry()
+	if err := c.RegisterTools(context.Background(), reg); err != nil {
+		t.Fatal(err)
+	}
+	srv := newFakeServer(t,
+		fakeToolCalls(toolCall("call_1", "mcp__test__add", `{"a":1,"b":2}`)),
+		fakeText("3"),
+	)
+
+	res, err := doReACT(context.Background(), srv.APIClient(), "qwen-plus", "sys", "1+2?", reg.Tools(), reg.Handlers(), 0, 4)
+	if err != nil {
+		t.Fatalf("doReACT error: %v", err)
+	}
+	if got := res.Messages[res.BaseMessagesLen+1]; got.Role != "tool" || got.Content != "3" {
+		t.Fatalf("tool message = %+v", got)
+	}
+	if got := toolNames(srv.Requests()[0].Req.Tools); !strings.Contains(got, "mcp__test__add") {
+		t.Fatalf("tools sent = %s", got)
+	}
+}
@@ -11,6 +11,12 @@ func cloneMessages(in []Message) []Message {
 			out[i].ToolCalls = make([]ToolCall, len(m.ToolCalls))
 			copy(out[i].ToolCalls, m.ToolCalls)
 		}
+		if len(m.ThinkingBlocks) > 0 {
+			out[i].ThinkingBlocks = append([]ThinkingBlock(nil), m.ThinkingBlocks...)
+		}
+		if len(m.Parts) > 0 {
+			out[i].Parts = append([]ContentPart(nil), m.Parts...)
+		}
 	}
 	return out
 }
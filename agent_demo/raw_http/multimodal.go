@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ContentPart is one element of an OpenAI-style content array. Only text and
+// image_url parts are supported; images are sent as URLs, usually data: URLs
+// built by ImageFilePart.
+type ContentPart struct {
+	Type     string    `json:"type"`
+	Text     string    `json:"text,omitempty"`
+	ImageURL *ImageURL `json:"image_url,omitempty"`
+}
+
+type ImageURL struct {
+	URL    string `json:"url"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// maxImageBytes keeps a local file well under the request size limits of
+// DashScope and Anthropic once base64 encoded.
+const maxImageBytes = 10 << 20
+
+func TextPart(text string) ContentPart {
+	return ContentPart{Type: "text", Text: text}
+}
+
+func ImageURLPart(url string) ContentPart {
+	return ContentPart{Type: "image_url", ImageURL: &ImageURL{URL: url}}
+}
+
+func ImageDataPart(mimeType string, data []byte) ContentPart {
+	return ImageURLPart("data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data))
+}
+
+// ImageFilePart reads a local image into a data: URL part. The MIME type
+// comes from the file contents, falling back to the extension.
+func ImageFilePart(path string) (ContentPart, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ContentPart{}, err
+	}
+	if len(data) > maxImageBytes {
+		return ContentPart{}, fmt.Errorf("image %s: %d bytes exceeds the %d byte limit", path, len(data), maxImageBytes)
+	}
+	mimeType := http.DetectContentType(data)
+	if !strings.HasPrefix(mimeType, "image/") {
+		mimeType, _, _ = strings.Cut(mime.TypeByExtension(strings.ToLower(filepath.Ext(path))), ";")
+	}
+	if !strings.HasPrefix(mimeType, "image/") {
+		return ContentPart{}, fmt.Errorf("image %s: unsupported content type", path)
+	}
+	return ImageDataPart(mimeType, data), nil
+}
+
+// UserMessage builds a user message; with parts it becomes a content array
+// whose first element is the text.
+func UserMessage(text string, parts ...ContentPart) Message {
+	if len(parts) == 0 {
+		return Message{Role: "user", Content: text}
+	}
+	all := make([]ContentPart, 0, len(parts)+1)
+	if text != "" {
+		all = append(all, TextPart(text))
+	}
+	return Message{Role: "user", Parts: append(all, parts...)}
+}
+
+// parseDataURL returns the MIME type and decoded payload of a base64 data:
+// URL; ok is false for remote URLs and malformed data URLs.
+func parseDataURL(url string) (mimeType string, data []byte, ok bool) {
+	rest, found := strings.CutPrefix(url, "data:")
+	if !found {
+		return "", nil, false
+	}
+	meta, payload, found := strings.Cut(rest, ",")
+	if !found || !strings.HasSuffix(meta, ";base64") {
+		return "", nil, false
+	}
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return "", nil, false
+	}
+	return strings.TrimSuffix(meta, ";base64"), data, true
+}
+
+// placeholder renders a part for transcripts: text verbatim, images as
+// "[image image/png, 1234 bytes]" so logs never carry the base64 payload.
+func (p ContentPart) placeholder() string {
+	switch p.Type {
+	case "text":
+		return p.Text
+	case "image_url":
+		if p.ImageURL == nil {
+			return "[image]"
+		}
+		if mimeType, data, ok := parseDataURL(p.ImageURL.URL); ok {
+			return fmt.Sprintf("[image %s, %d bytes]", mimeType, len(data))
+		}
+		return fmt.Sprintf("[image %s]", oneLine(p.ImageURL.URL, 80))
+	}
+	return fmt.Sprintf("[%s]", p.Type)
+}
+
+// Text returns the message text: Content, or the content parts with images
+// replaced by placeholders.
+func (m Message) Text() string {
+	if len(m.Parts) == 0 {
+		return m.Content
+	}
+	out := make([]string, 0, len(m.Parts)+1)
+	if m.Content != "" {
+		out = append(out, m.Content)
+	}
+	for _, p := range m.Parts {
+		out = append(out, p.placeholder())
+	}
+	return strings.Join(out, "\n")
+}
+
+type messageJSON Message
+
+// MarshalJSON sends Parts as the content array when present, and Content as
+// a plain string otherwise, so text-only messages encode exactly as before.
+func (m Message) MarshalJSON() ([]byte, error) {
+	if len(m.Parts) == 0 {
+		return json.Marshal(messageJSON(m))
+	}
+	parts := m.Parts
+	if m.Content != "" {
+		parts = append([]ContentPart{TextPart(m.Content)}, parts...)
+	}
+	return json.Marshal(struct {
+		messageJSON
+		Content []ContentPart `json:"content"`
+	}{messageJSON(m), parts})
+}
+
+// UnmarshalJSON accepts content as a string or as a content-part array.
+func (m *Message) UnmarshalJSON(b []byte) error {
+	var aux struct {
+		messageJSON
+		Content json.RawMessage `json:"content,omitempty"`
+	}
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	*m = Message(aux.messageJSON)
+	raw := strings.TrimSpace(string(aux.Content))
+	switch {
+	case raw == "" || raw == "null":
+	case raw[0] == '[':
+		if err := json.Unmarshal(aux.Content, &m.Parts); err != nil {
+			return fmt.Errorf("decode message content: %w", err)
+		}
+	default:
+		if err := json.Unmarshal(aux.Content, &m.Content); err != nil {
+			return fmt.Errorf("decode message content: %w", err)
+		}
+	}
+	return nil
+}
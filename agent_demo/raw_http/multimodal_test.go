@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// onePixelPNG is a valid 1x1 transparent PNG.
+var onePixelPNG, _ = base64.StdEncoding.DecodeString("iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAQAAAC1HAwCAAAAC0lEQVR42mNkYAAAAAYAAjCB0C8AAAAASUVORK5CYII=")
+
+func writeTestImage(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "pixel.bin")
+	if err := os.WriteFile(path, onePixelPNG, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestMessageJSON_ContentStringOrParts(t *testing.T) {
+	b, err := json.Marshal(Message{Role: "user", Content: "hi"})
+	if err != nil || string(b) != `{"role":"user","content":"hi"}` {
+		t.Fatalf("text message = %s, %v", b, err)
+	}
+
+	m := UserMessage("what is this?", ImageURLPart("https://example.com/a.png"))
+	b, err = json.Marshal(m)
+	want := `{"role":"user","content":[{"type":"text","text":"what is this?"},{"type":"image_url","image_url":{"url":"https://example.com/a.png"}}]}`
+	if err != nil || string(b) != want {
+		t.Fatalf("parts message = %s, %v", b, err)
+	}
+
+	var back Message
+	if err := json.Unmarshal(b, &back); err != nil || back.Content != "" || len(back.Parts) != 2 || back.Parts[1].ImageURL.URL != "https://example.com/a.png" {
+		t.Fatalf("decoded = %+v, %v", back, err)
+	}
+	if err := json.Unmarshal([]byte(`{"role":"assistant","content":"ok","tool_call_id":"x"}`), &back); err != nil || back.Content != "ok" || back.Parts != nil || back.ToolCallID != "x" {
+		t.Fatalf("decoded = %+v, %v", back, err)
+	}
+}
+
+func TestImageFilePart(t *testing.T) {
+	part, err := ImageFilePart(writeTestImage(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(part.ImageURL.URL, "data:image/png;base64,") {
+		t.Fatalf("url = %.40s", part.ImageURL.URL)
+	}
+	if got := part.placeholder(); got != "[image image/png, 68 bytes]" {
+		t.Fatalf("placeholder = %q", got)
+	}
+
+	text := filepath.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(text, []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ImageFilePart(text); err == nil || !strings.Contains(err.Error(), "unsupported content type") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestSession_Offline_ChatParts(t *testing.T) {
+	srv := newFakeServer(t, fakeText("a red pixel"))
+	sess := NewSessionWithClient(srv.APIClient(), "qwen-vl-plus")
+	img, err := ImageFilePart(writeTestImage(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sess.ChatParts(context.Background(), "describe", img); err != nil {
+		t.Fatal(err)
+	}
+	body := srv.Requests()[0].Body
+	if !bytes.Contains(body, []byte(`{"type":"text","text":"describe"},{"type":"image_url","image_url":{"url":"data:image/png;base64,`)) {
+		t.Fatalf("body = %s", body)
+	}
+	if p := sess.Turns()[0].Prompt; p != "describe\n[image image/png, 68 bytes]" {
+		t.Fatalf("turn prompt = %q", p)
+	}
+
+	out := RenderReACTResult(&ReACTResult{Messages: sess.Messages()})
+	if !strings.Contains(out, "[image image/png, 68 bytes]") || strings.Contains(out, "base64") {
+		t.Fatalf("render:\n%s", out)
+	}
+
+	var buf bytes.Buffer
+	if err := sess.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadSessionWithClient(&buf, srv.APIClient())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msgs := loaded.Messages(); len(msgs[0].Parts) != 2 || msgs[0].Parts[1].ImageURL.URL != img.ImageURL.URL {
+		t.Fatalf("restored messages = %+v", msgs)
+	}
+}
+
+func TestAnthropicProvider_ImageParts(t *testing.T) {
+	req := ChatCompletionRequest{
+		Model: "claude-sonnet-4-5",
+		Messages: []Message{UserMessage("compare",
+			ImageDataPart("image/png", onePixelPNG),
+			ImageURLPart("https://example.com/b.jpg"),
+		)},
+	}
+	body, err := (&AnthropicProvider{}).EncodeRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := base64.StdEncoding.EncodeToString(onePixelPNG)
+	for _, want := range []string{
+		`{"type":"text","text":"compare"}`,
+		`{"type":"image","source":{"type":"base64","media_type":"image/png","data":"` + data + `"}}`,
+		`{"type":"image","source":{"type":"url","url":"https://example.com/b.jpg"}}`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Fatalf("body missing %s: %s", want, body)
+		}
+	}
+}
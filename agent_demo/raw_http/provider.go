@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+type Provider interface {
+	Name() string
+	EncodeRequest(req ChatCompletionRequest) ([]byte, error)
+	SetHeaders(h http.Header, apiKey string)
+	DecodeResponse(body []byte, out *ChatCompletionResponse) error
+}
+
+type OpenAIProvider struct{}
+
+func (OpenAIProvider) Name() string { return "openai" }
+
+func (OpenAIProvider) EncodeRequest(req ChatCompletionRequest) ([]byte, error) {
+	b, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	return mergeExtraFields(b, req.Extra)
+}
+
+func (OpenAIProvider) SetHeaders(h http.Header, apiKey string) {
+	h.Set("Authorization", "Bearer "+apiKey)
+	h.Set("Content-Type", "application/json")
+}
+
+func (OpenAIProvider) DecodeResponse(body []byte, out *ChatCompletionResponse) error {
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("decode json: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) provider() Provider {
+	if c.Provider == nil {
+		return OpenAIProvider{}
+	}
+	return c.Provider
+}
+
+type providerSpec struct {
+	keyEnv       string
+	defaultModel string
+	newClient    func(endpoint, apiKey string, timeout time.Duration) *Client
+}
+
+func lookupProvider(name string) (providerSpec, error) {
+	switch name {
+	case "", "openai":
+		return providerSpec{keyEnv: "QWEN_API_KEY", defaultModel: "qwen-plus", newClient: NewClient}, nil
+	case "anthropic":
+		return providerSpec{keyEnv: "ANTHROPIC_API_KEY", defaultModel: "claude-sonnet-4-5", newClient: NewAnthropicClient}, nil
+	}
+	return providerSpec{}, fmt.Errorf("unknown provider %q", name)
+}
@@ -5,7 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"sync"
+	"strings"
 )
 
 type ToolHandler func(ctx context.Context, args json.RawMessage) (string, error)
@@ -15,9 +15,31 @@ type ReACTResult struct {
 	BaseMessagesLen int
 	Messages        []Message
 	Invokes         []*InvokeResult
+	Panics          []ToolPanic
+	Loops           []ToolLoop
+
+	// Forced is set when Final came from the extra tool_choice "none" call
+	// made by WithForcedFinalAnswer after maxSteps ran out.
+	Forced bool
+}
+
+var ErrMaxSteps = errors.New("exceeded max steps")
+
+const DefaultForcedFinalPrompt = `已达到工具调用的步数上限，不能再调用任何工具。
+请根据目前已经获得的信息直接给出最终答案；如果信息不完整，请说明已经确认了什么、还缺少什么。`
+
+// WithForcedFinalAnswer turns max-steps exhaustion into one more call that
+// asks the model for a final answer (prompt, or DefaultForcedFinalPrompt if
+// empty) instead of returning ErrMaxSteps.
+func WithForcedFinalAnswer(prompt string) ReACTOption {
+	return func(c *reactConfig) {
+		c.forceFinal = true
+		c.forceFinalPrompt = prompt
+	}
 }
 
-func doReACTWithHistory(ctx context.Context, client *Client, model string, messages []Message, tools []Tool, handlers map[string]ToolHandler, temperature float64, maxSteps int) (*ReACTResult, error) {
+func doReACTWithHistory(ctx context.Context, client *Client, model string, messages []Message, tools []Tool, handlers map[string]ToolHandler, temperature float64, maxSteps int, opts ...ReACTOption) (*ReACTResult, error) {
+	cfg := newReACTConfig(opts)
 	history := cloneMessages(messages)
 	result := &ReACTResult{
 		BaseMessagesLen: len(history),
@@ -30,73 +52,142 @@ func doReACTWithHistory(ctx context.Context, client *Client, model string, messa
 	if len(tools) > 0 && handlers == nil {
 		return result, errors.New("handlers is nil")
 	}
+	if err := cfg.loadSchemas(tools); err != nil {
+		return result, err
+	}
 
+	loops := newLoopDetector(cfg.loopMaxRepeats)
+	warnedAt := 0
 	for step := 0; step < maxSteps; step++ {
-		req := ChatCompletionRequest{
-			Model:       model,
-			Messages:    history,
-			Temperature: temperature,
-			Stream:      false,
-			Tools:       tools,
-		}
-		if len(tools) > 0 {
-			req.ToolChoice = "auto"
+		cfg.emit(ReACTEvent{Type: EventStepStart, Step: step + 1})
+		req := cfg.newRequest(model, history, tools, temperature)
+		choice, err := cfg.toolChoiceFor(step+1, maxSteps, tools)
+		if err != nil {
+			result.Messages = history
+			cfg.emit(ReACTEvent{Type: EventLoopError, Step: step + 1, Err: err})
+			return result, err
 		}
+		req.ToolChoice = choice
 
+		cfg.emit(ReACTEvent{Type: EventRequestSent, Step: step + 1, Request: &req})
 		invoke, err := client.Invoke(ctx, req)
+		cfg.emitResponse(step+1, invoke, err)
 		result.Invokes = append(result.Invokes, invoke)
 		if err != nil {
 			result.Messages = history
+			cfg.emit(ReACTEvent{Type: EventLoopError, Step: step + 1, Err: err})
 			return result, err
 		}
 
 		msg := invoke.Response.Choices[0].Message
+		// The approver may rewrite arguments; keep the recorded response as sent.
+		msg.ToolCalls = append([]ToolCall(nil), msg.ToolCalls...)
 		history = append(history, msg)
 
 		if len(msg.ToolCalls) == 0 {
 			result.Final = msg.Content
 			result.Messages = history
+			cfg.emit(ReACTEvent{Type: EventFinal, Step: step + 1, Final: msg.Content})
 			return result, nil
 		}
 
-		results := make([]Message, len(msg.ToolCalls))
-		var wg sync.WaitGroup
-		wg.Add(len(msg.ToolCalls))
-		for i, tc := range msg.ToolCalls {
-			tc := tc
-			i := i
-			go func() {
-				defer wg.Done()
-				handler, ok := handlers[tc.Function.Name]
-				var out string
-				if !ok {
-					out = fmt.Sprintf("tool not found: %s", tc.Function.Name)
-				} else {
-					toolOut, err := handler(ctx, json.RawMessage(tc.Function.Arguments))
-					if err != nil {
-						out = fmt.Sprintf("tool error: %v", err)
-					} else {
-						out = toolOut
-					}
-				}
-				results[i] = Message{
-					Role:       "tool",
-					ToolCallID: tc.ID,
-					Content:    out,
-				}
-			}()
+		loop := loops.observe(step+1, msg.ToolCalls)
+		if loop != nil {
+			// A warning only escalates while it is recent; a loop long after
+			// the model recovered gets a fresh warning.
+			recentWarning := warnedAt > 0 && step+1-warnedAt < loops.window()
+			loop.Stopped = cfg.loopAction == LoopStop || (cfg.loopAction == LoopWarnThenStop && recentWarning)
+			if !recentWarning {
+				warnedAt = step + 1
+			}
+			result.Loops = append(result.Loops, *loop)
+			cfg.emit(ReACTEvent{Type: EventLoopDetected, Step: step + 1, Loop: loop})
 		}
-		wg.Wait()
+		if loop != nil && loop.Stopped {
+			for _, tc := range msg.ToolCalls {
+				history = append(history, toolResultMessage(tc, "tool call skipped: loop detected"))
+			}
+			result.Messages = history
+			err := fmt.Errorf("%w at step %d: %s", ErrToolLoop, step+1, loop)
+			cfg.emit(ReACTEvent{Type: EventLoopError, Step: step + 1, Err: err})
+			return result, err
+		}
+
+		results, panics := executeToolCalls(ctx, cfg, step+1, msg.ToolCalls, handlers)
+		result.Panics = append(result.Panics, panics...)
 		history = append(history, results...)
+		if loop != nil {
+			history = append(history, Message{Role: "user", Content: loopCorrection(loop)})
+		}
 	}
 
 	result.Messages = history
-	return result, fmt.Errorf("exceeded max steps (%d)", maxSteps)
+	if cfg.forceFinal {
+		return forceFinalAnswer(ctx, client, cfg, model, temperature, tools, maxSteps, result)
+	}
+	err := fmt.Errorf("%w (%d)", ErrMaxSteps, maxSteps)
+	cfg.emit(ReACTEvent{Type: EventLoopError, Step: maxSteps, Err: err})
+	return result, err
+}
+
+// forceFinalAnswer makes one extra call after maxSteps tool steps, with
+// tool_choice "none" and a prompt asking the model to answer with what it
+// already has. The prompt stays in the history so the transcript is honest.
+func forceFinalAnswer(ctx context.Context, client *Client, cfg *reactConfig, model string, temperature float64, tools []Tool, maxSteps int, result *ReACTResult) (*ReACTResult, error) {
+	step := maxSteps + 1
+	prompt := cfg.forceFinalPrompt
+	if prompt == "" {
+		prompt = DefaultForcedFinalPrompt
+	}
+	history := append(result.Messages, Message{Role: "user", Content: prompt})
+	result.Messages = history
+
+	cfg.emit(ReACTEvent{Type: EventStepStart, Step: step})
+	req := cfg.newRequest(model, history, tools, temperature)
+	if len(tools) > 0 {
+		req.ToolChoice = ToolChoiceNone()
+	}
+	cfg.emit(ReACTEvent{Type: EventRequestSent, Step: step, Request: &req})
+	invoke, err := client.Invoke(ctx, req)
+	cfg.emitResponse(step, invoke, err)
+	result.Invokes = append(result.Invokes, invoke)
+	if err != nil {
+		err = fmt.Errorf("%w (%d); forced final answer failed: %w", ErrMaxSteps, maxSteps, err)
+		cfg.emit(ReACTEvent{Type: EventLoopError, Step: step, Err: err})
+		return result, err
+	}
+
+	msg := invoke.Response.Choices[0].Message
+	// Some backends ignore tool_choice "none"; unanswered tool calls would
+	// leave the history unusable for the next turn.
+	msg.ToolCalls = nil
+	if strings.TrimSpace(msg.Content) == "" {
+		err := fmt.Errorf("%w (%d); forced final answer was empty", ErrMaxSteps, maxSteps)
+		cfg.emit(ReACTEvent{Type: EventLoopError, Step: step, Err: err})
+		return result, err
+	}
+	result.Messages = append(history, msg)
+	result.Final = msg.Content
+	result.Forced = true
+	cfg.emit(ReACTEvent{Type: EventFinal, Step: step, Final: msg.Content, Forced: true})
+	return result, nil
+}
+
+func (c *reactConfig) newRequest(model string, messages []Message, tools []Tool, temperature float64) ChatCompletionRequest {
+	req := ChatCompletionRequest{
+		Model:          model,
+		Messages:       messages,
+		Temperature:    Ptr(temperature),
+		Tools:          tools,
+		ResponseFormat: c.responseFormat,
+	}
+	c.sampling.Apply(&req)
+	return req
 }
 
-func doReACT(ctx context.Context, client *Client, model, systemPrompt, userPrompt string, tools []Tool, handlers map[string]ToolHandler, temperature float64, maxSteps int) (*ReACTResult, error) {
+func doReACT(ctx context.Context, client *Client, model, systemPrompt, userPrompt string, tools []Tool, handlers map[string]ToolHandler, temperature float64, maxSteps int, opts ...ReACTOption) (*ReACTResult, error) {
 	return doReACTWithHistory(ctx, client, model, []Message{
 		{Role: "system", Content: systemPrompt},
 		{Role: "user", Content: userPrompt},
-	}, tools, handlers, temperature, maxSteps)
+	}, tools, handlers, temperature, maxSteps, opts...)
 }
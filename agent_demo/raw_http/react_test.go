@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"os"
 	"strings"
 	"testing"
@@ -134,3 +135,184 @@ Do not answer until step 3 is completed. Then answer in Chinese in 1 sentence.`
 	}
 	t.Log("\n" + RenderReACTResult(res))
 }
+
+func TestDoReACT_Offline_ThreeToolChain(t *testing.T) {
+	srv := newFakeServer(t,
+		fakeToolCalls(toolCall("call_1", "get_current_date", `{}`)),
+		fakeToolCalls(toolCall("call_2", "get_current_location", `{}`)),
+		fakeToolCalls(toolCall("call_3", "get_weather_by_date", `{"date":"2024-01-02","location":"San Francisco, CA","unit":"celsius"}`)),
+		FakeResponse{Message: Message{Content: "今天旧金山晴，20 度。"}, Usage: &Usage{TotalTokens: 42}},
+	)
+
+	var callOrder []string
+	handlers := map[string]ToolHandler{
+		"get_current_date": func(ctx context.Context, args json.RawMessage) (string, error) {
+			callOrder = append(callOrder, "get_current_date")
+			return "2024-01-02", nil
+		},
+		"get_current_location": func(ctx context.Context, args json.RawMessage) (string, error) {
+			callOrder = append(callOrder, "get_current_location")
+			return "San Francisco, CA", nil
+		},
+		"get_weather_by_date": func(ctx context.Context, args json.RawMessage) (string, error) {
+			callOrder = append(callOrder, "get_weather_by_date")
+			return `{"weather":"sunny","temperature":20,"unit":"celsius"}`, nil
+		},
+	}
+	tools := []Tool{
+		{Type: "function", Function: ToolFunction{Name: "get_current_date"}},
+		{Type: "function", Function: ToolFunction{Name: "get_current_location"}},
+		{Type: "function", Function: ToolFunction{Name: "get_weather_by_date"}},
+	}
+
+	res, err := doReACT(context.Background(), srv.APIClient(), "qwen-plus", "sys", "今天天气如何？", tools, handlers, 0, 12)
+	if err != nil {
+		t.Fatalf("doReACT error: %v", err)
+	}
+	if strings.Join(callOrder, ",") != "get_current_date,get_current_location,get_weather_by_date" {
+		t.Fatalf("callOrder = %v", callOrder)
+	}
+	if res.Final != "今天旧金山晴，20 度。" {
+		t.Fatalf("final = %q", res.Final)
+	}
+	if len(res.Invokes) != 4 {
+		t.Fatalf("invokes = %d, want 4", len(res.Invokes))
+	}
+	if len(res.Messages) != res.BaseMessagesLen+7 {
+		t.Fatalf("messages = %d, want %d", len(res.Messages), res.BaseMessagesLen+7)
+	}
+
+	reqs := srv.Requests()
+	if reqs[0].Req.ToolChoice.String() != "auto" || len(reqs[0].Req.Tools) != 3 {
+		t.Fatalf("first request = %+v", reqs[0].Req)
+	}
+	last := reqs[3].Req.Messages
+	if got := last[len(last)-1]; got.Role != "tool" || got.ToolCallID != "call_3" {
+		t.Fatalf("last message sent = %+v", got)
+	}
+	if out := RenderReACTResult(res); !strings.Contains(out, "tokens=42") || !strings.Contains(out, "tool[call_3]") {
+		t.Fatalf("render:\n%s", out)
+	}
+}
+
+func TestDoReACT_Offline_ParallelCallsAndToolErrors(t *testing.T) {
+	srv := newFakeServer(t,
+		fakeToolCalls(
+			toolCall("call_a", "calculator", `{"expression":"1+2"}`),
+			toolCall("call_b", "calculator", `{"expression":"1/0"}`),
+			toolCall("call_c", "missing_tool", `{}`),
+		),
+		fakeText("done"),
+	)
+	tools, handlers := BuiltinTools()
+
+	res, err := doReACT(context.Background(), srv.APIClient(), "qwen-plus", "sys", "go", tools, handlers, 0, 4)
+	if err != nil {
+		t.Fatalf("doReACT error: %v", err)
+	}
+	toolMsgs := res.Messages[res.BaseMessagesLen+1 : res.BaseMessagesLen+4]
+	want := []struct{ id, content string }{
+		{"call_a", "3"},
+		{"call_b", "tool error: division by zero"},
+		{"call_c", "tool not found: missing_tool"},
+	}
+	for i, w := range want {
+		if toolMsgs[i].ToolCallID != w.id || toolMsgs[i].Content != w.content {
+			t.Fatalf("tool msg %d = %+v, want %+v", i, toolMsgs[i], w)
+		}
+	}
+}
+
+func TestDoReACT_Offline_ExceededMaxSteps(t *testing.T) {
+	srv := newFakeServer(t,
+		fakeToolCalls(toolCall("call_1", "calculator", `{"expression":"1"}`)),
+		fakeToolCalls(toolCall("call_2", "calculator", `{"expression":"2"}`)),
+	)
+	tools, handlers := BuiltinTools()
+
+	res, err := doReACT(context.Background(), srv.APIClient(), "qwen-plus", "sys", "go", tools, handlers, 0, 2)
+	if err == nil || !strings.Contains(err.Error(), "exceeded max steps (2)") {
+		t.Fatalf("err = %v", err)
+	}
+	if !errors.Is(err, ErrMaxSteps) || res.Forced {
+		t.Fatalf("err = %v, forced = %v", err, res.Forced)
+	}
+	if len(res.Invokes) != 2 || len(res.Messages) != res.BaseMessagesLen+4 {
+		t.Fatalf("partial result = %d invokes, %d messages", len(res.Invokes), len(res.Messages))
+	}
+}
+
+func TestDoReACT_Offline_ForcedFinalAnswer(t *testing.T) {
+	srv := newFakeServer(t,
+		fakeToolCalls(toolCall("call_1", "calculator", `{"expression":"1"}`)),
+		fakeToolCalls(toolCall("call_2", "calculator", `{"expression":"2"}`)),
+		FakeResponse{
+			Message: Message{Role: "assistant", Content: "partial: 1 and 2", ToolCalls: []ToolCall{toolCall("call_3", "calculator", `{"expression":"3"}`)}},
+		},
+	)
+	tools, handlers := BuiltinTools()
+	rec := &eventRecorder{}
+
+	res, err := doReACT(context.Background(), srv.APIClient(), "qwen-plus", "sys", "go", tools, handlers, 0, 2, WithForcedFinalAnswer(""), WithObserver(rec))
+	if err != nil {
+		t.Fatalf("doReACT error: %v", err)
+	}
+	if !res.Forced || res.Final != "partial: 1 and 2" || len(res.Invokes) != 3 {
+		t.Fatalf("result = %+v", res)
+	}
+	last := srv.Requests()[2].Req
+	if last.ToolChoice.String() != "none" || len(last.Tools) != len(tools) {
+		t.Fatalf("forced request tool_choice = %q, tools = %d", last.ToolChoice, len(last.Tools))
+	}
+	if prompt := last.Messages[len(last.Messages)-1]; prompt.Role != "user" || prompt.Content != DefaultForcedFinalPrompt {
+		t.Fatalf("forced prompt = %+v", prompt)
+	}
+	final := res.Messages[len(res.Messages)-1]
+	if final.Role != "assistant" || len(final.ToolCalls) != 0 {
+		t.Fatalf("final message = %+v", final)
+	}
+	ev := rec.events[len(rec.events)-1]
+	if ev.Type != EventFinal || !ev.Forced || ev.Step != 3 {
+		t.Fatalf("final event = %+v", ev)
+	}
+	if out := RenderReACTResult(res); !strings.Contains(out, "!! final answer forced after max steps") {
+		t.Fatalf("render:\n%s", out)
+	}
+}
+
+func TestDoReACT_Offline_InvokeErrorKeepsPartialHistory(t *testing.T) {
+	srv := newFakeServer(t,
+		fakeToolCalls(toolCall("call_1", "calculator", `{"expression":"1+1"}`)),
+		fakeError(400, `{"error":{"message":"bad request","type":"invalid_request_error"}}`),
+	)
+	tools, handlers := BuiltinTools()
+
+	res, err := doReACT(context.Background(), srv.APIClient(), "qwen-plus", "sys", "go", tools, handlers, 0, 4)
+	if ErrorKindOf(err) != ErrorKindInvalidRequest {
+		t.Fatalf("err = %v", err)
+	}
+	if len(res.Invokes) != 2 || len(res.Messages) != res.BaseMessagesLen+2 {
+		t.Fatalf("partial result = %d invokes, %d messages", len(res.Invokes), len(res.Messages))
+	}
+}
+
+func TestDoReACT_Offline_ForcedFinalAnswerEmpty(t *testing.T) {
+	srv := newFakeServer(t,
+		fakeToolCalls(toolCall("call_1", "calculator", `{"expression":"1"}`)),
+		// The backend ignores tool_choice "none" and answers with tool calls only.
+		fakeToolCalls(toolCall("call_2", "calculator", `{"expression":"2"}`)),
+	)
+	tools, handlers := BuiltinTools()
+	sess := NewSessionWithClient(srv.APIClient(), "qwen-plus")
+	sess.EnableTools(tools, handlers)
+	sess.SetMaxSteps(1)
+	sess.SetReACTOptions(WithForcedFinalAnswer(""))
+
+	_, err := sess.Chat(context.Background(), "go")
+	if !errors.Is(err, ErrMaxSteps) || !strings.Contains(err.Error(), "forced final answer was empty") {
+		t.Fatalf("err = %v", err)
+	}
+	if len(sess.Messages()) != 0 || len(sess.Turns()) != 0 {
+		t.Fatalf("empty answer was stored: %+v", sess.Messages())
+	}
+}
@@ -24,7 +24,21 @@ func RenderInvokeResult(invoke *InvokeResult) string {
 	}
 
 	var b strings.Builder
-	fmt.Fprintf(&b, "== Invoke (status=%d, latency=%s) ==\n\n", invoke.StatusCode, invoke.Duration.Round(time.Millisecond))
+	fmt.Fprintf(&b, "== Invoke (status=%d, latency=%s) ==\n", invoke.StatusCode, invoke.Duration.Round(time.Millisecond))
+	if len(invoke.Attempts) > 1 {
+		for i, at := range invoke.Attempts {
+			status := fmt.Sprintf("%d", at.StatusCode)
+			if at.StatusCode == 0 {
+				status = "error"
+			}
+			fmt.Fprintf(&b, "attempt %d: status=%s, latency=%s", i+1, status, at.Duration.Round(time.Millisecond))
+			if at.Delay > 0 {
+				fmt.Fprintf(&b, ", retry_in=%s", at.Delay.Round(time.Millisecond))
+			}
+			b.WriteByte('\n')
+		}
+	}
+	b.WriteByte('\n')
 	b.WriteString(RenderReACTResult(res))
 	return b.String()
 }
@@ -35,11 +49,26 @@ func RenderReACTResult(res *ReACTResult) string {
 	}
 	var b strings.Builder
 
+	type callKey struct{ step, index int }
+	panics := make(map[callKey]ToolPanic, len(res.Panics))
+	for _, p := range res.Panics {
+		panics[callKey{p.Step, p.CallIndex}] = p
+	}
+
 	invokeIdx := 0
+	step, callIdx := 0, 0
 	for i, m := range res.Messages {
 		label := m.Role
+		var (
+			p        ToolPanic
+			panicked bool
+		)
 		switch m.Role {
 		case "assistant":
+			if i >= res.BaseMessagesLen {
+				step++
+				callIdx = 0
+			}
 			if i >= res.BaseMessagesLen && invokeIdx < len(res.Invokes) {
 				inv := res.Invokes[invokeIdx]
 				invokeIdx++
@@ -50,7 +79,10 @@ func RenderReACTResult(res *ReACTResult) string {
 					}
 					label = fmt.Sprintf("assistant#%d (finish=%s, latency=%s)", invokeIdx, finish, inv.Duration.Round(time.Millisecond))
 					if inv.Response.Usage != nil && inv.Response.Usage.TotalTokens > 0 {
-						label = fmt.Sprintf("%s, tokens=%d", label, inv.Response.Usage.TotalTokens)
+						label = fmt.Sprintf("%s, tokens=%d%s", label, inv.Response.Usage.TotalTokens, usageDetail(inv.Response.Usage))
+					}
+					if retries := len(inv.Attempts) - 1; retries > 0 {
+						label = fmt.Sprintf("%s, retries=%d", label, retries)
 					}
 				} else {
 					label = fmt.Sprintf("assistant#%d", invokeIdx)
@@ -62,9 +94,20 @@ func RenderReACTResult(res *ReACTResult) string {
 			} else {
 				label = "tool"
 			}
+			if i >= res.BaseMessagesLen {
+				p, panicked = panics[callKey{step, callIdx}]
+				callIdx++
+			}
+		}
+
+		if panicked {
+			label = fmt.Sprintf("%s PANIC (step=%d)", label, p.Step)
 		}
 
 		fmt.Fprintf(&b, "%02d %s:\n", i+1, label)
+		if panicked {
+			fmt.Fprintf(&b, "  !! %s panicked: %s\n", p.Tool, p.Value)
+		}
 
 		if m.Role == "assistant" && len(m.ToolCalls) > 0 {
 			b.WriteString("  tool_calls:\n")
@@ -79,7 +122,7 @@ func RenderReACTResult(res *ReACTResult) string {
 			}
 		}
 
-		content := strings.TrimSpace(m.Content)
+		content := strings.TrimSpace(m.Text())
 		thinking := strings.TrimSpace(m.ReasoningContent)
 		if thinking == "" && m.Role == "assistant" {
 			thinkFromTags, rest := extractThinkTags(content)
@@ -108,6 +151,20 @@ func RenderReACTResult(res *ReACTResult) string {
 
 		b.WriteByte('\n')
 	}
+
+	for _, l := range res.Loops {
+		action := "corrected"
+		if l.Stopped {
+			action = "stopped"
+		}
+		fmt.Fprintf(&b, "!! loop detected at step %d (%s): %s\n", l.Step, action, l)
+	}
+	if res.Forced {
+		b.WriteString("!! final answer forced after max steps (tool_choice=none)\n")
+	}
+	if u := res.Usage(); u.TotalTokens > 0 && len(res.Invokes) > 1 {
+		fmt.Fprintf(&b, "usage (%d calls): %s\n", len(res.Invokes), &u)
+	}
 	return b.String()
 }
 
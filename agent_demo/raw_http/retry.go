@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	Jitter      float64
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   500 * time.Millisecond,
+		MaxDelay:    10 * time.Second,
+		Jitter:      0.2,
+	}
+}
+
+type InvokeAttempt struct {
+	StatusCode int
+	Duration   time.Duration
+	Err        string
+	RetryAfter time.Duration
+	Delay      time.Duration
+}
+
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	if p.BaseDelay <= 0 {
+		return 0
+	}
+	d := p.BaseDelay
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if p.MaxDelay > 0 && d >= p.MaxDelay {
+			d = p.MaxDelay
+			break
+		}
+	}
+	if p.Jitter > 0 {
+		d = time.Duration(float64(d) * (1 + p.Jitter*(2*rand.Float64()-1)))
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return d
+}
+
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusRequestTimeout,
+		http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+		529:
+		return true
+	}
+	return false
+}
+
+// retryableTransportError reports whether err happened before the request
+// could reach the server (dial failures, refused connections). Anything later,
+// such as a timeout or reset after the body was written, may have been
+// processed already, so retrying it is not safe for non-idempotent POSTs.
+func retryableTransportError(err error) bool {
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	var op *net.OpError
+	return errors.As(err, &op) && op.Op == "dial"
+}
+
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// postWithRetry sends payload until it gets a non-retryable answer or the
+// policy is exhausted. A final retryable response is handed back with its
+// body buffered so callers decode it exactly like a first-try failure.
+func (c *Client) postWithRetry(ctx context.Context, payload []byte) (*http.Response, []InvokeAttempt, error) {
+	policy := c.Retry
+	maxAttempts := policy.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 1
+	}
+
+	var attempts []InvokeAttempt
+	for n := 1; ; n++ {
+		start := time.Now()
+		resp, err := c.post(ctx, payload)
+		at := InvokeAttempt{Duration: time.Since(start)}
+
+		if err != nil {
+			at.Err = err.Error()
+			if n >= maxAttempts || ctx.Err() != nil || !retryableTransportError(err) {
+				return nil, append(attempts, at), err
+			}
+		} else {
+			at.StatusCode = resp.StatusCode
+			if !retryableStatus(resp.StatusCode) {
+				attempts = append(attempts, at)
+				return resp, attempts, nil
+			}
+			body, readErr := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			resp.Body = io.NopCloser(bytes.NewReader(body))
+			at.Err = strings.TrimSpace(string(body))
+			if at.Err == "" {
+				at.Err = resp.Status
+			}
+			at.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+			if n >= maxAttempts || readErr != nil {
+				attempts = append(attempts, at)
+				return resp, attempts, nil
+			}
+			if policy.MaxDelay > 0 && at.RetryAfter > policy.MaxDelay {
+				attempts = append(attempts, at)
+				return resp, attempts, nil
+			}
+		}
+
+		at.Delay = policy.backoff(n)
+		if at.RetryAfter > at.Delay {
+			at.Delay = at.RetryAfter
+		}
+		attempts = append(attempts, at)
+		if err := sleepCtx(ctx, at.Delay); err != nil {
+			return nil, attempts, fmt.Errorf("retry wait: %w", err)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const okCompletion = `{"id":"c1","choices":[{"index":0,"message":{"role":"assistant","content":"hi"},"finish_reason":"stop"}]}`
+
+func TestClientInvoke_RetriesThenSucceeds(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch calls.Add(1) {
+		case 1:
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, "rate limited", http.StatusTooManyRequests)
+		case 2:
+			http.Error(w, "upstream down", http.StatusServiceUnavailable)
+		default:
+			io.WriteString(w, okCompletion)
+		}
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL, "test-key", 5*time.Second)
+	client.Retry = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+
+	invoke, err := client.Invoke(context.Background(), ChatCompletionRequest{Model: "qwen-plus"})
+	if err != nil {
+		t.Fatalf("Invoke error: %v", err)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Fatalf("calls = %d, want 3", got)
+	}
+	if len(invoke.Attempts) != 3 {
+		t.Fatalf("attempts = %+v, want 3", invoke.Attempts)
+	}
+	if invoke.Attempts[0].StatusCode != http.StatusTooManyRequests || invoke.Attempts[2].StatusCode != http.StatusOK {
+		t.Fatalf("attempts = %+v", invoke.Attempts)
+	}
+
+	res := &ReACTResult{
+		Messages: []Message{invoke.Response.Choices[0].Message},
+		Invokes:  []*InvokeResult{invoke},
+	}
+	if out := RenderReACTResult(res); !strings.Contains(out, "retries=2") {
+		t.Fatalf("render missing retries:\n%s", out)
+	}
+}
+
+func TestClientInvoke_NoRetryOnClientError(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, "bad request", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL, "test-key", 5*time.Second)
+	client.Retry = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}
+
+	invoke, err := client.Invoke(context.Background(), ChatCompletionRequest{Model: "qwen-plus"})
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("calls = %d, want 1", got)
+	}
+	if len(invoke.Attempts) != 1 {
+		t.Fatalf("attempts = %+v, want 1", invoke.Attempts)
+	}
+}
+
+func TestClientInvoke_RetryAfterBeyondMaxDelay(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Retry-After", "120")
+		http.Error(w, "slow down", http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL, "test-key", 5*time.Second)
+	client.Retry = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Second}
+
+	_, err := client.Invoke(context.Background(), ChatCompletionRequest{Model: "qwen-plus"})
+	if err == nil || !strings.Contains(err.Error(), "http 429") {
+		t.Fatalf("err = %v, want http 429", err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Fatalf("calls = %d, want 1", got)
+	}
+}
+
+func TestClientInvoke_RetriesDialErrorsOnly(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedURL := "http://" + ln.Addr().String()
+	ln.Close()
+
+	client := NewClient(closedURL, "test-key", time.Second)
+	client.Retry = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}
+	invoke, err := client.Invoke(context.Background(), ChatCompletionRequest{Model: "qwen-plus"})
+	if err == nil || invoke == nil || len(invoke.Attempts) != 3 || invoke.Attempts[2].Err == "" || invoke.Duration <= 0 {
+		t.Fatalf("invoke = %+v, err = %v", invoke, err)
+	}
+
+	// A timeout after the request was sent may already have been processed.
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		time.Sleep(100 * time.Millisecond)
+		io.WriteString(w, okCompletion)
+	}))
+	defer srv.Close()
+	client = NewClient(srv.URL, "test-key", 20*time.Millisecond)
+	client.Retry = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}
+	invoke, err = client.Invoke(context.Background(), ChatCompletionRequest{Model: "qwen-plus"})
+	if err == nil || calls.Load() != 1 || len(invoke.Attempts) != 1 {
+		t.Fatalf("calls = %d, attempts = %+v, err = %v", calls.Load(), invoke.Attempts, err)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if got := parseRetryAfter("3", now); got != 3*time.Second {
+		t.Fatalf("seconds = %s", got)
+	}
+	if got := parseRetryAfter(now.Add(5*time.Second).Format(http.TimeFormat), now); got != 5*time.Second {
+		t.Fatalf("date = %s", got)
+	}
+	if got := parseRetryAfter("soon", now); got != 0 {
+		t.Fatalf("garbage = %s", got)
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: 300 * time.Millisecond}
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond, 300 * time.Millisecond}
+	for i, w := range want {
+		if got := p.backoff(i + 1); got != w {
+			t.Fatalf("backoff(%d) = %s, want %s", i+1, got, w)
+		}
+	}
+
+	p.Jitter = 0.5
+	for i := 0; i < 50; i++ {
+		if got := p.backoff(1); got < 50*time.Millisecond || got > 150*time.Millisecond {
+			t.Fatalf("jittered backoff = %s", got)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func Ptr[T any](v T) *T { return &v }
+
+// SamplingParams holds the optional generation knobs of a request. nil means
+// "not set, use the server default", so an explicit zero (temperature 0,
+// seed 0, parallel_tool_calls false) is still sent. Extra carries
+// provider-specific top-level fields such as DashScope's enable_thinking.
+type SamplingParams struct {
+	Temperature       *float64               `json:"temperature,omitempty"`
+	TopP              *float64               `json:"top_p,omitempty"`
+	MaxTokens         *int                   `json:"max_tokens,omitempty"`
+	Stop              []string               `json:"stop,omitempty"`
+	Seed              *int64                 `json:"seed,omitempty"`
+	PresencePenalty   *float64               `json:"presence_penalty,omitempty"`
+	FrequencyPenalty  *float64               `json:"frequency_penalty,omitempty"`
+	N                 *int                   `json:"n,omitempty"`
+	ParallelToolCalls *bool                  `json:"parallel_tool_calls,omitempty"`
+	Logprobs          *bool                  `json:"logprobs,omitempty"`
+	TopLogprobs       *int                   `json:"top_logprobs,omitempty"`
+	Extra             map[string]interface{} `json:"extra,omitempty"`
+}
+
+// Merge returns p with every field set in over taken from over. Extra is
+// merged key by key.
+func (p SamplingParams) Merge(over SamplingParams) SamplingParams {
+	out := p
+	if over.Temperature != nil {
+		out.Temperature = over.Temperature
+	}
+	if over.TopP != nil {
+		out.TopP = over.TopP
+	}
+	if over.MaxTokens != nil {
+		out.MaxTokens = over.MaxTokens
+	}
+	if over.Stop != nil {
+		out.Stop = over.Stop
+	}
+	if over.Seed != nil {
+		out.Seed = over.Seed
+	}
+	if over.PresencePenalty != nil {
+		out.PresencePenalty = over.PresencePenalty
+	}
+	if over.FrequencyPenalty != nil {
+		out.FrequencyPenalty = over.FrequencyPenalty
+	}
+	if over.N != nil {
+		out.N = over.N
+	}
+	if over.ParallelToolCalls != nil {
+		out.ParallelToolCalls = over.ParallelToolCalls
+	}
+	if over.Logprobs != nil {
+		out.Logprobs = over.Logprobs
+	}
+	if over.TopLogprobs != nil {
+		out.TopLogprobs = over.TopLogprobs
+	}
+	if len(over.Extra) > 0 {
+		out.Extra = make(map[string]interface{}, len(p.Extra)+len(over.Extra))
+		for k, v := range p.Extra {
+			out.Extra[k] = v
+		}
+		for k, v := range over.Extra {
+			out.Extra[k] = v
+		}
+	}
+	return out
+}
+
+func (p SamplingParams) isZero() bool {
+	return p.Temperature == nil && p.TopP == nil && p.MaxTokens == nil && p.Stop == nil &&
+		p.Seed == nil && p.PresencePenalty == nil && p.FrequencyPenalty == nil && p.N == nil &&
+		p.ParallelToolCalls == nil && p.Logprobs == nil && p.TopLogprobs == nil && len(p.Extra) == 0
+}
+
+// Apply copies the fields set in p onto req, leaving the others untouched.
+func (p SamplingParams) Apply(req *ChatCompletionRequest) {
+	cur := SamplingParams{
+		Temperature:       req.Temperature,
+		TopP:              req.TopP,
+		MaxTokens:         req.MaxTokens,
+		Stop:              req.Stop,
+		Seed:              req.Seed,
+		PresencePenalty:   req.PresencePenalty,
+		FrequencyPenalty:  req.FrequencyPenalty,
+		N:                 req.N,
+		ParallelToolCalls: req.ParallelToolCalls,
+		Logprobs:          req.Logprobs,
+		TopLogprobs:       req.TopLogprobs,
+		Extra:             req.Extra,
+	}.Merge(p)
+	req.Temperature = cur.Temperature
+	req.TopP = cur.TopP
+	req.MaxTokens = cur.MaxTokens
+	req.Stop = cur.Stop
+	req.Seed = cur.Seed
+	req.PresencePenalty = cur.PresencePenalty
+	req.FrequencyPenalty = cur.FrequencyPenalty
+	req.N = cur.N
+	req.ParallelToolCalls = cur.ParallelToolCalls
+	req.Logprobs = cur.Logprobs
+	req.TopLogprobs = cur.TopLogprobs
+	req.Extra = cur.Extra
+}
+
+// WithSampling sets sampling parameters for every request of the loop. Set
+// fields override the temperature argument of doReACT; repeated options are
+// merged in order.
+func WithSampling(p SamplingParams) ReACTOption {
+	return func(c *reactConfig) { c.sampling = c.sampling.Merge(p) }
+}
+
+// mergeExtraFields adds extra as top-level fields of the JSON object in
+// payload. Extra keys win over the encoded ones, so they can also override a
+// standard field the provider spells differently.
+func mergeExtraFields(payload []byte, extra map[string]interface{}) ([]byte, error) {
+	if len(extra) == 0 {
+		return payload, nil
+	}
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &obj); err != nil {
+		return nil, err
+	}
+	for k, v := range extra {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("encode extra field %q: %w", k, err)
+		}
+		obj[k] = b
+	}
+	return json.Marshal(obj)
+}
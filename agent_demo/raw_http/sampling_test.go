@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestSamplingParams_MergeAndEncode(t *testing.T) {
+	base := SamplingParams{
+		Temperature: Ptr(0.7),
+		MaxTokens:   Ptr(512),
+		Extra:       map[string]interface{}{"enable_thinking": true, "top_k": 20},
+	}
+	merged := base.Merge(SamplingParams{
+		Temperature:       Ptr(0.0),
+		Seed:              Ptr(int64(0)),
+		ParallelToolCalls: Ptr(false),
+		Stop:              []string{"###"},
+		Extra:             map[string]interface{}{"enable_thinking": false},
+	})
+	if base.Extra["enable_thinking"] != true {
+		t.Fatalf("Merge mutated the receiver's Extra")
+	}
+
+	req := ChatCompletionRequest{Model: "qwen-plus", Messages: []Message{{Role: "user", Content: "hi"}}}
+	merged.Apply(&req)
+	body, err := OpenAIProvider{}.EncodeRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"temperature":         0.0,
+		"max_tokens":          512.0,
+		"seed":                0.0,
+		"parallel_tool_calls": false,
+		"enable_thinking":     false,
+		"top_k":               20.0,
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Fatalf("%s = %#v, want %#v (body %s)", k, got[k], v, body)
+		}
+	}
+	if stop, _ := got["stop"].([]interface{}); len(stop) != 1 || stop[0] != "###" {
+		t.Fatalf("stop = %#v", got["stop"])
+	}
+	for _, k := range []string{"top_p", "n", "logprobs", "presence_penalty", "Extra", "extra"} {
+		if _, ok := got[k]; ok {
+			t.Fatalf("unset field %s was sent: %s", k, body)
+		}
+	}
+}
+
+func TestAnthropicProvider_SamplingParams(t *testing.T) {
+	req := ChatCompletionRequest{
+		Model:    "claude-sonnet-4-5",
+		Messages: []Message{{Role: "user", Content: "hi"}},
+		Tools:    []Tool{{Type: "function", Function: ToolFunction{Name: "now"}}},
+	}
+	SamplingParams{
+		Temperature:       Ptr(0.0),
+		TopP:              Ptr(0.9),
+		MaxTokens:         Ptr(100),
+		Stop:              []string{"END"},
+		ParallelToolCalls: Ptr(false),
+		Extra:             map[string]interface{}{"top_k": 5},
+	}.Apply(&req)
+	body, err := (&AnthropicProvider{MaxTokens: 4096}).EncodeRequest(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"temperature":0`, `"top_p":0.9`, `"max_tokens":100`, `"stop_sequences":["END"]`, `"disable_parallel_tool_use":true`, `"top_k":5`} {
+		if !strings.Contains(string(body), want) {
+			t.Fatalf("body missing %s: %s", want, body)
+		}
+	}
+}
+
+func TestSession_Offline_SamplingPerSessionAndPerCall(t *testing.T) {
+	srv := newFakeServer(t, fakeText("a"), fakeText("b"))
+	sess := NewSessionWithClient(srv.APIClient(), "qwen-plus")
+	sess.SetTemperature(0)
+	sess.SetSampling(SamplingParams{Seed: Ptr(int64(42)), TopP: Ptr(0.8), Extra: map[string]interface{}{"enable_thinking": false}})
+
+	if _, err := sess.Chat(context.Background(), "one"); err != nil {
+		t.Fatal(err)
+	}
+	sess.SetReACTOptions(WithSampling(SamplingParams{TopP: Ptr(0.5), MaxTokens: Ptr(64)}))
+	if _, err := sess.Chat(context.Background(), "two"); err != nil {
+		t.Fatal(err)
+	}
+
+	reqs := srv.Requests()
+	first, second := reqs[0].Req, reqs[1].Req
+	if first.Temperature == nil || *first.Temperature != 0 || *first.Seed != 42 || *first.TopP != 0.8 || first.MaxTokens != nil {
+		t.Fatalf("first request = %+v", first)
+	}
+	if !bytes.Contains(reqs[0].Body, []byte(`"enable_thinking":false`)) || !bytes.Contains(reqs[0].Body, []byte(`"temperature":0`)) {
+		t.Fatalf("first body = %s", reqs[0].Body)
+	}
+	if *second.Seed != 42 || *second.TopP != 0.5 || *second.MaxTokens != 64 {
+		t.Fatalf("second request = %+v", second)
+	}
+
+	var buf bytes.Buffer
+	if err := sess.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadSessionWithClient(&buf, srv.APIClient())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := loaded.Sampling(); p.Seed == nil || *p.Seed != 42 || p.Extra["enable_thinking"] != false {
+		t.Fatalf("restored sampling = %+v", p)
+	}
+}
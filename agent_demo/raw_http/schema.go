@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// normalizeSchema round-trips a hand-written schema through JSON so that
+// typed Go values such as []string become the generic []interface{} form.
+func normalizeSchema(schema map[string]interface{}) (map[string]interface{}, error) {
+	if schema == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(schema)
+	if err != nil {
+		return nil, err
+	}
+	var out map[string]interface{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func decodeJSONValue(raw []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("unexpected data after JSON value")
+	}
+	return v, nil
+}
+
+func ValidateToolArguments(schema map[string]interface{}, args string) error {
+	norm, err := normalizeSchema(schema)
+	if err != nil {
+		return fmt.Errorf("invalid schema: %v", err)
+	}
+	return validateNormalizedArgs(norm, args)
+}
+
+func validateNormalizedArgs(norm map[string]interface{}, args string) error {
+	if strings.TrimSpace(args) == "" {
+		args = "{}"
+	}
+	v, err := decodeJSONValue([]byte(args))
+	if err != nil {
+		return fmt.Errorf("arguments are not valid JSON: %v", err)
+	}
+	if norm == nil {
+		return nil
+	}
+	var errs []string
+	validateSchemaValue(norm, v, "$", &errs)
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func validateSchemaValue(schema map[string]interface{}, v interface{}, path string, errs *[]string) {
+	if types := schemaTypes(schema["type"]); len(types) > 0 {
+		ok := false
+		for _, t := range types {
+			if jsonTypeMatches(t, v) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			*errs = append(*errs, fmt.Sprintf("%s: expected %s, got %s", path, strings.Join(types, " or "), jsonTypeName(v)))
+			return
+		}
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok && len(enum) > 0 {
+		found := false
+		for _, e := range enum {
+			if jsonEqual(e, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			allowed, _ := json.Marshal(enum)
+			got, _ := json.Marshal(v)
+			*errs = append(*errs, fmt.Sprintf("%s: value %s is not one of %s", path, got, allowed))
+		}
+	}
+	if c, ok := schema["const"]; ok && !jsonEqual(c, v) {
+		want, _ := json.Marshal(c)
+		*errs = append(*errs, fmt.Sprintf("%s: must be %s", path, want))
+	}
+
+	switch val := v.(type) {
+	case map[string]interface{}:
+		validateSchemaObject(schema, val, path, errs)
+	case []interface{}:
+		if n, ok := schemaNumber(schema["minItems"]); ok && float64(len(val)) < n {
+			*errs = append(*errs, fmt.Sprintf("%s: expected at least %v items", path, n))
+		}
+		if n, ok := schemaNumber(schema["maxItems"]); ok && float64(len(val)) > n {
+			*errs = append(*errs, fmt.Sprintf("%s: expected at most %v items", path, n))
+		}
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range val {
+				validateSchemaValue(items, item, fmt.Sprintf("%s[%d]", path, i), errs)
+			}
+		}
+	case string:
+		n := float64(utf8.RuneCountInString(val))
+		if min, ok := schemaNumber(schema["minLength"]); ok && n < min {
+			*errs = append(*errs, fmt.Sprintf("%s: expected at least %v characters", path, min))
+		}
+		if max, ok := schemaNumber(schema["maxLength"]); ok && n > max {
+			*errs = append(*errs, fmt.Sprintf("%s: expected at most %v characters", path, max))
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			if re, err := regexp.Compile(pattern); err == nil && !re.MatchString(val) {
+				*errs = append(*errs, fmt.Sprintf("%s: %q does not match pattern %q", path, val, pattern))
+			}
+		}
+	case json.Number:
+		f, err := val.Float64()
+		if err != nil {
+			return
+		}
+		if min, ok := schemaNumber(schema["minimum"]); ok && f < min {
+			*errs = append(*errs, fmt.Sprintf("%s: must be >= %v", path, min))
+		}
+		if max, ok := schemaNumber(schema["maximum"]); ok && f > max {
+			*errs = append(*errs, fmt.Sprintf("%s: must be <= %v", path, max))
+		}
+	}
+}
+
+func validateSchemaObject(schema map[string]interface{}, obj map[string]interface{}, path string, errs *[]string) {
+	props, _ := schema["properties"].(map[string]interface{})
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, r := range required {
+			name, _ := r.(string)
+			if _, present := obj[name]; name != "" && !present {
+				*errs = append(*errs, fmt.Sprintf("%s.%s: required property is missing", path, name))
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		child := path + "." + k
+		if ps, ok := props[k].(map[string]interface{}); ok {
+			validateSchemaValue(ps, obj[k], child, errs)
+			continue
+		}
+		switch ap := schema["additionalProperties"].(type) {
+		case bool:
+			if !ap {
+				*errs = append(*errs, fmt.Sprintf("%s: unknown property", child))
+			}
+		case map[string]interface{}:
+			validateSchemaValue(ap, obj[k], child, errs)
+		}
+	}
+}
+
+func schemaTypes(t interface{}) []string {
+	switch v := t.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var out []string
+		for _, x := range v {
+			if s, ok := x.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func jsonTypeMatches(t string, v interface{}) bool {
+	switch t {
+	case "object":
+		_, ok := v.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := v.([]interface{})
+		return ok
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "null":
+		return v == nil
+	case "number":
+		_, ok := v.(json.Number)
+		return ok
+	case "integer":
+		n, ok := v.(json.Number)
+		if !ok {
+			return false
+		}
+		f, err := n.Float64()
+		return err == nil && f == math.Trunc(f)
+	}
+	return true
+}
+
+func jsonTypeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func schemaNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func jsonEqual(a, b interface{}) bool {
+	if n, ok := b.(json.Number); ok {
+		f, err := n.Float64()
+		if err != nil {
+			return false
+		}
+		b = f
+	}
+	if n, ok := a.(json.Number); ok {
+		f, err := n.Float64()
+		if err != nil {
+			return false
+		}
+		a = f
+	}
+	ab, err1 := json.Marshal(a)
+	bb, err2 := json.Marshal(b)
+	return err1 == nil && err2 == nil && bytes.Equal(ab, bb)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+var weatherSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"location": map[string]interface{}{"type": "string", "minLength": 1},
+		"unit":     map[string]interface{}{"type": "string", "enum": []string{"celsius", "fahrenheit"}},
+		"days":     map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 7},
+		"filters": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"tags": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+			},
+			"required":             []string{"tags"},
+			"additionalProperties": false,
+		},
+	},
+	"required": []string{"location"},
+}
+
+func TestValidateToolArguments(t *testing.T) {
+	cases := []struct {
+		args string
+		want string
+	}{
+		{`{"location":"Shanghai","unit":"celsius","days":3}`, ""},
+		{``, "$.location: required property is missing"},
+		{`{"location":"Shanghai","unit":"kelvin"}`, `$.unit: value "kelvin" is not one of ["celsius","fahrenheit"]`},
+		{`{"location":42}`, "$.location: expected string, got number"},
+		{`{"location":"x","days":2.5}`, "$.days: expected integer, got number"},
+		{`{"location":"x","days":9}`, "$.days: must be <= 7"},
+		{`{"location":"x","filters":{"tags":["a",1]}}`, "$.filters.tags[1]: expected string, got number"},
+		{`{"location":"x","filters":{"extra":true}}`, "$.filters.tags: required property is missing; $.filters.extra: unknown property"},
+		{`{"location":`, "arguments are not valid JSON"},
+		{`[]`, "$: expected object, got array"},
+	}
+	for _, c := range cases {
+		err := ValidateToolArguments(weatherSchema, c.args)
+		if c.want == "" {
+			if err != nil {
+				t.Fatalf("args %s: unexpected error %v", c.args, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Fatalf("args %s: err = %v, want %q", c.args, err, c.want)
+		}
+	}
+}
+
+func TestDoReACT_Offline_InvalidArgumentsNotDispatched(t *testing.T) {
+	srv := newFakeServer(t,
+		fakeToolCalls(toolCall("call_1", "get_weather", `{"unit":"kelvin"}`)),
+		fakeToolCalls(toolCall("call_2", "get_weather", `{"location":"Shanghai","unit":"celsius"}`)),
+		fakeText("sunny"),
+	)
+	var dispatched []string
+	tools := []Tool{{Type: "function", Function: ToolFunction{Name: "get_weather", Parameters: weatherSchema}}}
+	handlers := map[string]ToolHandler{
+		"get_weather": func(ctx context.Context, args json.RawMessage) (string, error) {
+			dispatched = append(dispatched, string(args))
+			return "sunny", nil
+		},
+	}
+
+	res, err := doReACT(context.Background(), srv.APIClient(), "qwen-plus", "sys", "weather?", tools, handlers, 0, 4)
+	if err != nil {
+		t.Fatalf("doReACT error: %v", err)
+	}
+	if len(dispatched) != 1 || !strings.Contains(dispatched[0], "Shanghai") {
+		t.Fatalf("dispatched = %v", dispatched)
+	}
+	feedback := res.Messages[res.BaseMessagesLen+1].Content
+	if !strings.HasPrefix(feedback, "tool error: invalid arguments for get_weather:") ||
+		!strings.Contains(feedback, "$.location: required property is missing") ||
+		!strings.Contains(feedback, `$.unit: value "kelvin"`) {
+		t.Fatalf("feedback = %q", feedback)
+	}
+}
+
+func TestDoReACT_Offline_WithoutArgValidation(t *testing.T) {
+	srv := newFakeServer(t,
+		fakeToolCalls(toolCall("call_1", "get_weather", `{"unit":"kelvin"}`)),
+		fakeText("done"),
+	)
+	called := false
+	tools := []Tool{{Type: "function", Function: ToolFunction{Name: "get_weather", Parameters: weatherSchema}}}
+	handlers := map[string]ToolHandler{
+		"get_weather": func(ctx context.Context, args json.RawMessage) (string, error) {
+			called = true
+			return "ok", nil
+		},
+	}
+	if _, err := doReACT(context.Background(), srv.APIClient(), "qwen-plus", "sys", "weather?", tools, handlers, 0, 4, WithoutArgValidation()); err != nil {
+		t.Fatalf("doReACT error: %v", err)
+	}
+	if !called {
+		t.Fatalf("handler not called with validation disabled")
+	}
+}
@@ -13,13 +13,23 @@ type Session struct {
 	client      *Client
 	model       string
 	temperature float64
+	sampling    SamplingParams
 	maxSteps    int
 
-	tools    []Tool
-	handlers map[string]ToolHandler
+	tools      []Tool
+	handlers   map[string]ToolHandler
+	registry   *ToolRegistry
+	savedTools []string
 
 	messages  []Message
 	lastReACT *ReACTResult
+	turns     []TurnRecord
+
+	contextPolicy  ContextPolicy
+	lastCompaction *Compaction
+
+	reactOpts []ReACTOption
+	observer  ReACTObserver
 }
 
 func NewSession(endpoint, model string, timeout time.Duration) (*Session, error) {
@@ -30,12 +40,16 @@ func NewSession(endpoint, model string, timeout time.Duration) (*Session, error)
 	if strings.TrimSpace(model) == "" {
 		model = "qwen-plus"
 	}
+	return NewSessionWithClient(NewClient(endpoint, apiKey, timeout), model), nil
+}
+
+func NewSessionWithClient(client *Client, model string) *Session {
 	return &Session{
-		client:      NewClient(endpoint, apiKey, timeout),
-		model:       model,
+		client:      client,
+		model:       strings.TrimSpace(model),
 		temperature: 0.7,
 		maxSteps:    8,
-	}, nil
+	}
 }
 
 func (s *Session) SetSystemPrompt(prompt string) {
@@ -49,13 +63,44 @@ func (s *Session) SetSystemPrompt(prompt string) {
 
 func (s *Session) SetTemperature(t float64) { s.temperature = t }
 
+// SetSampling sets the sampling parameters sent with every request of the
+// session. A Temperature set here overrides SetTemperature; WithSampling
+// options passed to SetReACTOptions override both.
+func (s *Session) SetSampling(p SamplingParams) { s.sampling = p }
+
+func (s *Session) Sampling() SamplingParams { return s.sampling }
+
 func (s *Session) EnableTools(tools []Tool, handlers map[string]ToolHandler) {
+	s.registry = nil
 	s.tools = tools
 	s.handlers = handlers
 }
 
+// UseToolRegistry makes the session read its tools from r at the start of
+// every turn, so Enable/Disable on r take effect from the next Chat call.
+func (s *Session) UseToolRegistry(r *ToolRegistry) {
+	s.registry = r
+	s.syncTools()
+}
+
+func (s *Session) ToolRegistry() *ToolRegistry { return s.registry }
+
+func (s *Session) syncTools() {
+	if s.registry == nil {
+		return
+	}
+	s.tools = s.registry.Tools()
+	s.handlers = s.registry.Handlers()
+}
+
 func (s *Session) SetMaxSteps(n int) { s.maxSteps = n }
 
+func (s *Session) SetReACTOptions(opts ...ReACTOption) { s.reactOpts = opts }
+
+// SetObserver receives the loop events of every Chat turn, including turns
+// without tools (which emit a single step). nil removes it.
+func (s *Session) SetObserver(obs ReACTObserver) { s.observer = obs }
+
 func (s *Session) Messages() []Message {
 	out := make([]Message, len(s.messages))
 	copy(out, s.messages)
@@ -64,6 +109,7 @@ func (s *Session) Messages() []Message {
 
 func (s *Session) Reset(keepSystem bool) {
 	s.lastReACT = nil
+	s.turns = nil
 	if !keepSystem {
 		s.messages = nil
 		return
@@ -76,48 +122,121 @@ func (s *Session) Reset(keepSystem bool) {
 }
 
 func (s *Session) Chat(ctx context.Context, userPrompt string) (string, error) {
+	return s.chat(ctx, UserMessage(strings.TrimSpace(userPrompt)))
+}
+
+// ChatParts sends a multimodal user turn: the text followed by parts such
+// as ImageFilePart. The turn record keeps image placeholders, not the data.
+func (s *Session) ChatParts(ctx context.Context, text string, parts ...ContentPart) (string, error) {
+	return s.chat(ctx, UserMessage(strings.TrimSpace(text), parts...))
+}
+
+// chat runs one turn; extra options apply to this turn only, after the
+// session's own.
+func (s *Session) chat(ctx context.Context, user Message, extra ...ReACTOption) (string, error) {
 	if s == nil || s.client == nil {
 		return "", errors.New("nil session")
 	}
 	s.lastReACT = nil
-	userPrompt = strings.TrimSpace(userPrompt)
+	s.lastCompaction = nil
+	userPrompt := strings.TrimSpace(user.Text())
 	if userPrompt == "" {
 		return "", errors.New("empty user prompt")
 	}
 
+	s.syncTools()
 	origLen := len(s.messages)
-	s.messages = append(s.messages, Message{Role: "user", Content: userPrompt})
+	s.messages = append(s.messages, user)
+	origLen -= s.compact(ctx, origLen)
+
+	started := time.Now()
+	for {
+		final, invokes, ranTools, err := s.turn(ctx, extra)
+		if err == nil {
+			rec := newTurnRecord(userPrompt, final, started, invokes)
+			rec.Forced = s.lastReACT != nil && s.lastReACT.Forced
+			s.turns = append(s.turns, rec)
+			return final, nil
+		}
+		// Retrying reruns the whole turn, so only do it while no tool has
+		// run (or been put to the approver) yet; tools may have side effects.
+		if ErrorKindOf(err) == ErrorKindContextLength && !ranTools {
+			if dropped := s.dropOldestTurn(origLen); dropped > 0 {
+				origLen -= dropped
+				continue
+			}
+		}
+		s.messages = s.messages[:origLen]
+		return "", err
+	}
+}
 
+// turn runs the ReACT loop (or a single request without tools) on the
+// current history. ranTools reports whether a failed turn had already
+// written tool results.
+func (s *Session) turn(ctx context.Context, extra []ReACTOption) (final string, invokes []*InvokeResult, ranTools bool, err error) {
+	opts := append([]ReACTOption{WithSampling(s.sampling)}, s.reactOpts...)
+	opts = append(opts, extra...)
+	opts = append(opts, WithObserver(s.observer))
 	if len(s.tools) > 0 {
-		res, err := doReACTWithHistory(ctx, s.client, s.model, s.messages, s.tools, s.handlers, s.temperature, s.maxSteps)
+		res, err := doReACTWithHistory(ctx, s.client, s.model, s.messages, s.tools, s.handlers, s.temperature, s.maxSteps, opts...)
 		if err != nil {
-			s.messages = s.messages[:origLen]
-			return "", err
+			return "", nil, res != nil && hasToolResults(res.Messages[res.BaseMessagesLen:]), err
 		}
 		s.messages = res.Messages
 		s.lastReACT = res
-		return res.Final, nil
+		return res.Final, res.Invokes, false, nil
 	}
 
-	invoke, err := s.client.Invoke(ctx, ChatCompletionRequest{
-		Model:       s.model,
-		Messages:    s.messages,
-		Temperature: s.temperature,
-		Stream:      false,
-	})
+	cfg := newReACTConfig(opts)
+	req := cfg.newRequest(s.model, s.messages, nil, s.temperature)
+	cfg.emit(ReACTEvent{Type: EventStepStart, Step: 1})
+	cfg.emit(ReACTEvent{Type: EventRequestSent, Step: 1, Request: &req})
+	invoke, err := s.client.Invoke(ctx, req)
+	cfg.emitResponse(1, invoke, err)
 	if err != nil {
-		s.messages = s.messages[:origLen]
-		return "", err
+		cfg.emit(ReACTEvent{Type: EventLoopError, Step: 1, Err: err})
+		return "", nil, false, err
 	}
 
 	msg := invoke.Response.Choices[0].Message
 	if len(msg.ToolCalls) > 0 {
-		s.messages = s.messages[:origLen]
-		return "", fmt.Errorf("model returned tool_calls; enable tools to execute them")
+		err := fmt.Errorf("model returned tool_calls; enable tools to execute them")
+		cfg.emit(ReACTEvent{Type: EventLoopError, Step: 1, Err: err})
+		return "", nil, false, err
 	}
 
 	s.messages = append(s.messages, msg)
-	return msg.Content, nil
+	cfg.emit(ReACTEvent{Type: EventFinal, Step: 1, Final: msg.Content})
+	return msg.Content, []*InvokeResult{invoke}, false, nil
+}
+
+func hasToolResults(msgs []Message) bool {
+	for _, m := range msgs {
+		if m.Role == "tool" {
+			return true
+		}
+	}
+	return false
+}
+
+// dropOldestTurn removes the oldest user turn (and every assistant/tool
+// message that followed it) that lies before index limit, so tool_call and
+// tool messages always leave together. It returns the number of messages removed.
+func (s *Session) dropOldestTurn(limit int) int {
+	start := 0
+	if len(s.messages) > 0 && s.messages[0].Role == "system" {
+		start = 1
+	}
+	if start >= limit {
+		return 0
+	}
+	end := start + 1
+	for end < limit && s.messages[end].Role != "user" {
+		end++
+	}
+	s.messages = append(s.messages[:start], s.messages[end:]...)
+	return end - start
 }
 
 func (s *Session) LastReACT() *ReACTResult {
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const SessionSchemaVersion = 1
+
+type SessionState struct {
+	Version int       `json:"version"`
+	SavedAt time.Time `json:"saved_at"`
+
+	Provider    string  `json:"provider,omitempty"`
+	Endpoint    string  `json:"endpoint,omitempty"`
+	TimeoutMS   int64   `json:"timeout_ms,omitempty"`
+	Model       string  `json:"model"`
+	Temperature float64 `json:"temperature"`
+	MaxSteps    int     `json:"max_steps"`
+
+	Retry     *RetryState     `json:"retry,omitempty"`
+	Anthropic *AnthropicState `json:"anthropic,omitempty"`
+
+	Sampling      *SamplingParams `json:"sampling,omitempty"`
+	ContextPolicy *ContextPolicy  `json:"context_policy,omitempty"`
+
+	Tools    []string     `json:"tools,omitempty"`
+	Messages []Message    `json:"messages"`
+	Turns    []TurnRecord `json:"turns,omitempty"`
+}
+
+// RetryState is the saved form of the client's RetryPolicy. A session saved
+// before it existed has none and keeps the provider's default policy.
+type RetryState struct {
+	MaxAttempts int     `json:"max_attempts"`
+	BaseDelayMS int64   `json:"base_delay_ms"`
+	MaxDelayMS  int64   `json:"max_delay_ms"`
+	Jitter      float64 `json:"jitter"`
+}
+
+func newRetryState(p RetryPolicy) *RetryState {
+	return &RetryState{
+		MaxAttempts: p.MaxAttempts,
+		BaseDelayMS: p.BaseDelay.Milliseconds(),
+		MaxDelayMS:  p.MaxDelay.Milliseconds(),
+		Jitter:      p.Jitter,
+	}
+}
+
+func (r RetryState) policy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: r.MaxAttempts,
+		BaseDelay:   time.Duration(r.BaseDelayMS) * time.Millisecond,
+		MaxDelay:    time.Duration(r.MaxDelayMS) * time.Millisecond,
+		Jitter:      r.Jitter,
+	}
+}
+
+// AnthropicState keeps the AnthropicProvider settings of an anthropic session.
+type AnthropicState struct {
+	MaxTokens      int  `json:"max_tokens"`
+	ThinkingBudget int  `json:"thinking_budget"`
+	CacheControl   bool `json:"cache_control"`
+}
+
+type TurnRecord struct {
+	Prompt    string       `json:"prompt"`
+	Final     string       `json:"final"`
+	Forced    bool         `json:"forced,omitempty"`
+	StartedAt time.Time    `json:"started_at"`
+	Invokes   []InvokeMeta `json:"invokes,omitempty"`
+}
+
+type InvokeMeta struct {
+	ResponseID   string `json:"response_id,omitempty"`
+	Model        string `json:"model,omitempty"`
+	StatusCode   int    `json:"status_code"`
+	DurationMS   int64  `json:"duration_ms"`
+	Attempts     int    `json:"attempts,omitempty"`
+	FinishReason string `json:"finish_reason,omitempty"`
+	Usage        *Usage `json:"usage,omitempty"`
+}
+
+func newTurnRecord(prompt, final string, started time.Time, invokes []*InvokeResult) TurnRecord {
+	rec := TurnRecord{Prompt: prompt, Final: final, StartedAt: started}
+	for _, inv := range invokes {
+		if inv == nil {
+			continue
+		}
+		meta := InvokeMeta{
+			ResponseID: inv.Response.ID,
+			Model:      inv.Response.Model,
+			StatusCode: inv.StatusCode,
+			DurationMS: inv.Duration.Milliseconds(),
+			Attempts:   len(inv.Attempts),
+			Usage:      inv.Response.Usage,
+		}
+		if len(inv.Response.Choices) > 0 {
+			meta.FinishReason = inv.Response.Choices[0].FinishReason
+		}
+		rec.Invokes = append(rec.Invokes, meta)
+	}
+	return rec
+}
+
+func (s *Session) State() SessionState {
+	st := SessionState{
+		Version:     SessionSchemaVersion,
+		SavedAt:     time.Now(),
+		Model:       s.model,
+		Temperature: s.temperature,
+		MaxSteps:    s.maxSteps,
+		Messages:    cloneMessages(s.messages),
+		Turns:       append([]TurnRecord(nil), s.turns...),
+	}
+	if s.client != nil {
+		st.Provider = s.client.provider().Name()
+		st.Endpoint = s.client.Endpoint
+		if s.client.HTTPClient != nil {
+			st.TimeoutMS = s.client.HTTPClient.Timeout.Milliseconds()
+		}
+		st.Retry = newRetryState(s.client.Retry)
+		if p, ok := s.client.Provider.(*AnthropicProvider); ok && p != nil {
+			st.Anthropic = &AnthropicState{MaxTokens: p.MaxTokens, ThinkingBudget: p.ThinkingBudget, CacheControl: p.CacheControl}
+		}
+	}
+	if !s.sampling.isZero() {
+		p := s.sampling
+		st.Sampling = &p
+	}
+	if s.contextPolicy != (ContextPolicy{}) {
+		p := s.contextPolicy
+		st.ContextPolicy = &p
+	}
+	s.syncTools()
+	for _, t := range s.tools {
+		st.Tools = append(st.Tools, t.Function.Name)
+	}
+	if st.Messages == nil {
+		st.Messages = []Message{}
+	}
+	return st
+}
+
+func (s *Session) Save(w io.Writer) error {
+	if s == nil {
+		return errors.New("nil session")
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(s.State()); err != nil {
+		return fmt.Errorf("encode session: %w", err)
+	}
+	return nil
+}
+
+func decodeSessionState(r io.Reader) (SessionState, error) {
+	var st SessionState
+	if err := json.NewDecoder(r).Decode(&st); err != nil {
+		return st, fmt.Errorf("decode session: %w", err)
+	}
+	if st.Version == 0 || st.Version > SessionSchemaVersion {
+		return st, fmt.Errorf("unsupported session schema version %d", st.Version)
+	}
+	return st, nil
+}
+
+// LoadSession rebuilds the client from the saved provider, endpoint, timeout,
+// retry policy and Anthropic settings, reading the API key from the provider's
+// environment variable. Tool handlers and ReACT options (tool timeouts,
+// parallelism, approvals, observers) are code and are not saved: register the
+// tools again and call SetReACTOptions / SetObserver on the loaded session.
+func LoadSession(r io.Reader) (*Session, error) {
+	st, err := decodeSessionState(r)
+	if err != nil {
+		return nil, err
+	}
+	spec, err := lookupProvider(st.Provider)
+	if err != nil {
+		return nil, err
+	}
+	apiKey := strings.TrimSpace(os.Getenv(spec.keyEnv))
+	if apiKey == "" {
+		return nil, fmt.Errorf("missing API key: set %s", spec.keyEnv)
+	}
+	timeout := time.Duration(st.TimeoutMS) * time.Millisecond
+	client := spec.newClient(st.Endpoint, apiKey, timeout)
+	if st.Retry != nil {
+		client.Retry = st.Retry.policy()
+	}
+	if p, ok := client.Provider.(*AnthropicProvider); ok && st.Anthropic != nil {
+		p.MaxTokens = st.Anthropic.MaxTokens
+		p.ThinkingBudget = st.Anthropic.ThinkingBudget
+		p.CacheControl = st.Anthropic.CacheControl
+	}
+	return restoreSession(st, client), nil
+}
+
+// LoadSessionWithClient restores the conversation onto client as given; the
+// saved provider, endpoint, retry and Anthropic settings are ignored.
+func LoadSessionWithClient(r io.Reader, client *Client) (*Session, error) {
+	st, err := decodeSessionState(r)
+	if err != nil {
+		return nil, err
+	}
+	return restoreSession(st, client), nil
+}
+
+func restoreSession(st SessionState, client *Client) *Session {
+	s := NewSessionWithClient(client, st.Model)
+	s.temperature = st.Temperature
+	if st.MaxSteps > 0 {
+		s.maxSteps = st.MaxSteps
+	}
+	s.messages = cloneMessages(st.Messages)
+	s.turns = st.Turns
+	s.savedTools = st.Tools
+	if st.Sampling != nil {
+		s.sampling = *st.Sampling
+	}
+	if st.ContextPolicy != nil {
+		s.contextPolicy = *st.ContextPolicy
+	}
+	return s
+}
+
+func (s *Session) Turns() []TurnRecord {
+	return append([]TurnRecord(nil), s.turns...)
+}
+
+func (s *Session) MissingTools() []string {
+	s.syncTools()
+	enabled := make(map[string]bool, len(s.tools))
+	for _, t := range s.tools {
+		enabled[t.Function.Name] = true
+	}
+	var out []string
+	for _, name := range s.savedTools {
+		if !enabled[name] {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+type FileSessionStore struct {
+	Dir string
+}
+
+func NewFileSessionStore(dir string) (*FileSessionStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create session dir: %w", err)
+	}
+	return &FileSessionStore{Dir: dir}, nil
+}
+
+func (fs *FileSessionStore) path(id string) (string, error) {
+	id = strings.TrimSpace(id)
+	if id == "" || id != filepath.Base(id) || strings.HasPrefix(id, ".") {
+		return "", fmt.Errorf("invalid session id %q", id)
+	}
+	return filepath.Join(fs.Dir, id+".json"), nil
+}
+
+func (fs *FileSessionStore) Save(id string, s *Session) error {
+	p, err := fs.path(id)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(fs.Dir, "."+id+"-*.tmp")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := s.Save(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close temp file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		return fmt.Errorf("rename session file: %w", err)
+	}
+	return nil
+}
+
+func (fs *FileSessionStore) open(id string) (*os.File, error) {
+	p, err := fs.path(id)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, fmt.Errorf("open session: %w", err)
+	}
+	return f, nil
+}
+
+func (fs *FileSessionStore) Load(id string) (*Session, error) {
+	f, err := fs.open(id)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadSession(f)
+}
+
+func (fs *FileSessionStore) LoadWithClient(id string, client *Client) (*Session, error) {
+	f, err := fs.open(id)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadSessionWithClient(f, client)
+}
+
+func (fs *FileSessionStore) List() ([]string, error) {
+	entries, err := os.ReadDir(fs.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("list sessions: %w", err)
+	}
+	var ids []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		ids = append(ids, strings.TrimSuffix(name, ".json"))
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func (fs *FileSessionStore) Delete(id string) error {
+	p, err := fs.path(id)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("delete session: %w", err)
+	}
+	return nil
+}
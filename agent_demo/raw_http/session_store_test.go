@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSession_SaveLoadResumes(t *testing.T) {
+	srv := newFakeServer(t,
+		fakeToolCalls(toolCall("call_1", "calculator", `{"expression":"6*7"}`)),
+		FakeResponse{Message: Message{Content: "42"}, Usage: &Usage{PromptTokens: 20, CompletionTokens: 2, TotalTokens: 22}},
+		fakeText("84"),
+	)
+	sess := NewSessionWithClient(srv.APIClient(), "qwen-plus")
+	sess.SetSystemPrompt("sys")
+	sess.SetTemperature(0.2)
+	sess.SetMaxSteps(5)
+	sess.EnableTools(BuiltinTools())
+	if _, err := sess.Chat(context.Background(), "6*7?"); err != nil {
+		t.Fatalf("Chat error: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := sess.Save(&buf); err != nil {
+		t.Fatalf("Save error: %v", err)
+	}
+	if !strings.Contains(buf.String(), `"version": 1`) {
+		t.Fatalf("missing schema version:\n%s", buf.String())
+	}
+
+	loaded, err := LoadSessionWithClient(&buf, srv.APIClient())
+	if err != nil {
+		t.Fatalf("LoadSessionWithClient error: %v", err)
+	}
+	if loaded.model != "qwen-plus" || loaded.temperature != 0.2 || loaded.maxSteps != 5 {
+		t.Fatalf("settings = %s %v %d", loaded.model, loaded.temperature, loaded.maxSteps)
+	}
+	if got, want := len(loaded.Messages()), len(sess.Messages()); got != want {
+		t.Fatalf("messages = %d, want %d", got, want)
+	}
+	if missing := loaded.MissingTools(); strings.Join(missing, ",") != "now,calculator" {
+		t.Fatalf("MissingTools = %v", missing)
+	}
+	turns := loaded.Turns()
+	if len(turns) != 1 || len(turns[0].Invokes) != 2 || turns[0].Final != "42" || turns[0].Invokes[1].Usage.TotalTokens != 22 {
+		t.Fatalf("turns = %+v", turns)
+	}
+
+	loaded.EnableTools(BuiltinTools())
+	if missing := loaded.MissingTools(); len(missing) != 0 {
+		t.Fatalf("MissingTools after enable = %v", missing)
+	}
+	if _, err := loaded.Chat(context.Background(), "double it"); err != nil {
+		t.Fatalf("resumed Chat error: %v", err)
+	}
+	reqs := srv.Requests()
+	if n := len(reqs[2].Req.Messages); n != 6 {
+		t.Fatalf("resumed request sent %d messages, want 6", n)
+	}
+}
+
+func TestLoadSession_RejectsUnknownVersion(t *testing.T) {
+	_, err := LoadSessionWithClient(strings.NewReader(`{"version":99,"model":"m","messages":[]}`), nil)
+	if err == nil || !strings.Contains(err.Error(), "version 99") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestLoadSession_UsesProviderKey(t *testing.T) {
+	t.Setenv("ANTHROPIC_API_KEY", "")
+	state := `{"version":1,"provider":"anthropic","endpoint":"http://127.0.0.1:1","model":"claude-sonnet-4-5","messages":[]}`
+	if _, err := LoadSession(strings.NewReader(state)); err == nil || !strings.Contains(err.Error(), "ANTHROPIC_API_KEY") {
+		t.Fatalf("err = %v", err)
+	}
+
+	t.Setenv("ANTHROPIC_API_KEY", "k")
+	sess, err := LoadSession(strings.NewReader(state))
+	if err != nil {
+		t.Fatalf("LoadSession error: %v", err)
+	}
+	if sess.client.provider().Name() != "anthropic" || sess.client.Endpoint != "http://127.0.0.1:1" {
+		t.Fatalf("client = %+v", sess.client)
+	}
+}
+
+func TestLoadSession_RestoresRetryAndAnthropicSettings(t *testing.T) {
+	t.Setenv("ANTHROPIC_API_KEY", "k")
+	client := NewAnthropicClient("http://127.0.0.1:1", "k", 5*time.Second)
+	client.Retry = RetryPolicy{MaxAttempts: 5, BaseDelay: time.Second, MaxDelay: 20 * time.Second, Jitter: 0.1}
+	client.Provider = &AnthropicProvider{MaxTokens: 2048, ThinkingBudget: 1024}
+	var buf bytes.Buffer
+	if err := NewSessionWithClient(client, "claude-sonnet-4-5").Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	sess, err := LoadSession(&buf)
+	if err != nil {
+		t.Fatalf("LoadSession error: %v", err)
+	}
+	if sess.client.Retry != client.Retry {
+		t.Fatalf("retry = %+v", sess.client.Retry)
+	}
+	p, ok := sess.client.Provider.(*AnthropicProvider)
+	if !ok || *p != (AnthropicProvider{MaxTokens: 2048, ThinkingBudget: 1024}) {
+		t.Fatalf("provider = %+v", sess.client.Provider)
+	}
+	if sess.client.HTTPClient.Timeout != 5*time.Second {
+		t.Fatalf("timeout = %s", sess.client.HTTPClient.Timeout)
+	}
+}
+
+func TestFileSessionStore(t *testing.T) {
+	store, err := NewFileSessionStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileSessionStore error: %v", err)
+	}
+	srv := newFakeServer(t, fakeText("hello"))
+	sess := NewSessionWithClient(srv.APIClient(), "qwen-plus")
+	if _, err := sess.Chat(context.Background(), "hi"); err != nil {
+		t.Fatalf("Chat error: %v", err)
+	}
+
+	if err := store.Save("../escape", sess); err == nil {
+		t.Fatalf("expected invalid id error")
+	}
+	if err := store.Save("alpha", sess); err != nil {
+		t.Fatalf("Save error: %v", err)
+	}
+	ids, err := store.List()
+	if err != nil || strings.Join(ids, ",") != "alpha" {
+		t.Fatalf("List = %v, %v", ids, err)
+	}
+	loaded, err := store.LoadWithClient("alpha", srv.APIClient())
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+	if msgs := loaded.Messages(); len(msgs) != 2 || msgs[1].Content != "hello" {
+		t.Fatalf("messages = %+v", msgs)
+	}
+	if err := store.Delete("alpha"); err != nil {
+		t.Fatalf("Delete error: %v", err)
+	}
+	if ids, _ := store.List(); len(ids) != 0 {
+		t.Fatalf("List after delete = %v", ids)
+	}
+}
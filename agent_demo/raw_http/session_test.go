@@ -100,3 +100,90 @@ func TestSession_MultiTurn_WithTools_Integration(t *testing.T) {
 		t.Fatalf("history len = %d, want at least %d", got, 2*len(turns)+1)
 	}
 }
+
+func TestSession_Offline_MultiTurnWithTools(t *testing.T) {
+	srv := newFakeServer(t,
+		fakeText("我是助手。"),
+		fakeToolCalls(toolCall("call_1", "calculator", `{"expression":"6*7"}`)),
+		fakeText("42"),
+	)
+	sess := NewSessionWithClient(srv.APIClient(), "qwen-plus")
+	sess.SetSystemPrompt("You are a helpful assistant.")
+	sess.EnableTools(BuiltinTools())
+
+	for i, prompt := range []string{"你好", "6*7=?"} {
+		if _, err := sess.Chat(context.Background(), prompt); err != nil {
+			t.Fatalf("turn%d error: %v", i+1, err)
+		}
+	}
+	if res := sess.LastReACT(); res == nil || res.Final != "42" {
+		t.Fatalf("LastReACT = %+v", res)
+	}
+
+	msgs := sess.Messages()
+	roles := make([]string, len(msgs))
+	for i, m := range msgs {
+		roles[i] = m.Role
+	}
+	if got := strings.Join(roles, ","); got != "system,user,assistant,user,assistant,tool,assistant" {
+		t.Fatalf("roles = %s", got)
+	}
+
+	reqs := srv.Requests()
+	if n := len(reqs[2].Req.Messages); n != 6 {
+		t.Fatalf("third request sent %d messages, want 6", n)
+	}
+}
+
+func TestSession_Offline_ErrorRollsBackTurn(t *testing.T) {
+	srv := newFakeServer(t,
+		fakeText("first"),
+		fakeError(500, `{"error":{"message":"boom"}}`),
+	)
+	sess := NewSessionWithClient(srv.APIClient(), "qwen-plus")
+
+	if _, err := sess.Chat(context.Background(), "one"); err != nil {
+		t.Fatalf("turn1 error: %v", err)
+	}
+	if _, err := sess.Chat(context.Background(), "two"); ErrorKindOf(err) != ErrorKindServer {
+		t.Fatalf("turn2 err = %v", err)
+	}
+	if got := len(sess.Messages()); got != 2 {
+		t.Fatalf("history len = %d, want 2", got)
+	}
+}
+
+func TestSession_Offline_ToolCallsWithoutTools(t *testing.T) {
+	srv := newFakeServer(t, fakeToolCalls(toolCall("call_1", "now", `{}`)))
+	sess := NewSessionWithClient(srv.APIClient(), "qwen-plus")
+
+	if _, err := sess.Chat(context.Background(), "time?"); err == nil || !strings.Contains(err.Error(), "enable tools") {
+		t.Fatalf("err = %v", err)
+	}
+	if got := len(sess.Messages()); got != 0 {
+		t.Fatalf("history len = %d, want 0", got)
+	}
+}
+
+func TestSession_Offline_ForcedFinalKeepsHistory(t *testing.T) {
+	srv := newFakeServer(t,
+		fakeToolCalls(toolCall("call_1", "calculator", `{"expression":"6*7"}`)),
+		fakeText("42, probably"),
+	)
+	sess := NewSessionWithClient(srv.APIClient(), "qwen-plus")
+	sess.EnableTools(BuiltinTools())
+	sess.SetMaxSteps(1)
+	sess.SetReACTOptions(WithForcedFinalAnswer("answer now"))
+
+	final, err := sess.Chat(context.Background(), "6*7?")
+	if err != nil || final != "42, probably" {
+		t.Fatalf("final = %q, err = %v", final, err)
+	}
+	// user, assistant(tool_calls), tool, forced prompt, assistant
+	if msgs := sess.Messages(); len(msgs) != 5 || msgs[2].Content != "42" || msgs[3].Content != "answer now" {
+		t.Fatalf("messages = %+v", msgs)
+	}
+	if turns := sess.Turns(); len(turns) != 1 || !turns[0].Forced {
+		t.Fatalf("turns = %+v", turns)
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+type StreamDelta struct {
+	Index            int
+	Content          string
+	ReasoningContent string
+	ToolCalls        []ToolCallDelta
+	FinishReason     string
+}
+
+type ToolCallDelta struct {
+	Index    int              `json:"index"`
+	ID       string           `json:"id,omitempty"`
+	Type     string           `json:"type,omitempty"`
+	Function ToolCallFunction `json:"function"`
+}
+
+type streamChunk struct {
+	ID      string `json:"id,omitempty"`
+	Object  string `json:"object,omitempty"`
+	Created int64  `json:"created,omitempty"`
+	Model   string `json:"model,omitempty"`
+
+	Choices []struct {
+		Index int `json:"index"`
+		Delta struct {
+			Role             string          `json:"role,omitempty"`
+			Content          string          `json:"content,omitempty"`
+			ReasoningContent string          `json:"reasoning_content,omitempty"`
+			ToolCalls        []ToolCallDelta `json:"tool_calls,omitempty"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason,omitempty"`
+	} `json:"choices,omitempty"`
+
+	Usage *Usage `json:"usage,omitempty"`
+
+	Error *apiErrorBody `json:"error,omitempty"`
+}
+
+func (c *Client) InvokeStream(ctx context.Context, req ChatCompletionRequest, onDelta func(StreamDelta)) (*InvokeResult, error) {
+	if p := c.provider(); p.Name() != (OpenAIProvider{}).Name() {
+		return nil, fmt.Errorf("streaming is not supported by provider %s", p.Name())
+	}
+	req.Stream = true
+	if req.StreamOptions == nil {
+		req.StreamOptions = &StreamOptions{IncludeUsage: true}
+	}
+	payload, err := c.marshalRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+
+	resp, attempts, err := c.postWithRetry(ctx, payload)
+	if err != nil {
+		return c.failedInvoke(req, payload, start, attempts), err
+	}
+	defer resp.Body.Close()
+
+	out := &InvokeResult{
+		Endpoint:   c.Endpoint,
+		StatusCode: resp.StatusCode,
+		Request:    req,
+		RawRequest: payload,
+		Attempts:   attempts,
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		bodyBytes, err := io.ReadAll(resp.Body)
+		out.Duration = time.Since(start)
+		out.RawResponse = bodyBytes
+		if err != nil {
+			return out, fmt.Errorf("read response: %w", err)
+		}
+		return out, checkStatus(resp, bodyBytes)
+	}
+
+	var raw bytes.Buffer
+	acc := &streamAccumulator{}
+	err = readSSE(io.TeeReader(resp.Body, &raw), func(data []byte) error {
+		var chunk streamChunk
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return fmt.Errorf("decode chunk: %w", err)
+		}
+		if chunk.Error != nil && strings.TrimSpace(chunk.Error.Message) != "" {
+			return newBodyAPIError(resp, data, chunk.Error)
+		}
+		for _, d := range acc.add(&chunk) {
+			if onDelta != nil {
+				onDelta(d)
+			}
+		}
+		return nil
+	})
+	out.Duration = time.Since(start)
+	out.RawResponse = raw.Bytes()
+	out.Response = acc.response()
+	if err != nil {
+		return out, err
+	}
+	if len(out.Response.Choices) == 0 {
+		return out, errors.New("empty choices")
+	}
+	return out, nil
+}
+
+func readSSE(r io.Reader, onData func([]byte) error) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	var data []string
+	flush := func() (bool, error) {
+		if len(data) == 0 {
+			return false, nil
+		}
+		payload := strings.Join(data, "\n")
+		data = data[:0]
+		if strings.TrimSpace(payload) == "[DONE]" {
+			return true, nil
+		}
+		return false, onData([]byte(payload))
+	}
+
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" {
+			done, err := flush()
+			if err != nil || done {
+				return err
+			}
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+		if v, ok := strings.CutPrefix(line, "data:"); ok {
+			data = append(data, strings.TrimPrefix(v, " "))
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("read stream: %w", err)
+	}
+	_, err := flush()
+	return err
+}
+
+type streamAccumulator struct {
+	resp    ChatCompletionResponse
+	choices []Choice
+}
+
+func (a *streamAccumulator) add(chunk *streamChunk) []StreamDelta {
+	if chunk.ID != "" {
+		a.resp.ID = chunk.ID
+	}
+	if chunk.Created != 0 {
+		a.resp.Created = chunk.Created
+	}
+	if chunk.Model != "" {
+		a.resp.Model = chunk.Model
+	}
+	if chunk.Usage != nil {
+		u := *chunk.Usage
+		a.resp.Usage = &u
+	}
+
+	var deltas []StreamDelta
+	for _, ch := range chunk.Choices {
+		for len(a.choices) <= ch.Index {
+			a.choices = append(a.choices, Choice{Index: len(a.choices), Message: Message{Role: "assistant"}})
+		}
+		dst := &a.choices[ch.Index]
+		if ch.Delta.Role != "" {
+			dst.Message.Role = ch.Delta.Role
+		}
+		dst.Message.Content += ch.Delta.Content
+		dst.Message.ReasoningContent += ch.Delta.ReasoningContent
+		for _, tc := range ch.Delta.ToolCalls {
+			for len(dst.Message.ToolCalls) <= tc.Index {
+				dst.Message.ToolCalls = append(dst.Message.ToolCalls, ToolCall{Type: "function"})
+			}
+			call := &dst.Message.ToolCalls[tc.Index]
+			if tc.ID != "" {
+				call.ID = tc.ID
+			}
+			if tc.Type != "" {
+				call.Type = tc.Type
+			}
+			call.Function.Name += tc.Function.Name
+			call.Function.Arguments += tc.Function.Arguments
+		}
+		if ch.FinishReason != "" {
+			dst.FinishReason = ch.FinishReason
+		}
+
+		d := StreamDelta{
+			Index:            ch.Index,
+			Content:          ch.Delta.Content,
+			ReasoningContent: ch.Delta.ReasoningContent,
+			ToolCalls:        ch.Delta.ToolCalls,
+			FinishReason:     ch.FinishReason,
+		}
+		if d.Content != "" || d.ReasoningContent != "" || len(d.ToolCalls) > 0 || d.FinishReason != "" {
+			deltas = append(deltas, d)
+		}
+	}
+	return deltas
+}
+
+func (a *streamAccumulator) response() ChatCompletionResponse {
+	out := a.resp
+	if len(a.choices) > 0 {
+		out.Object = "chat.completion"
+		out.Choices = make([]Choice, len(a.choices))
+		copy(out.Choices, a.choices)
+	}
+	return out
+}
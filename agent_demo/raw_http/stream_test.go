@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const sseToolCallStream = `data: {"id":"chatcmpl-1","object":"chat.completion.chunk","created":1,"model":"qwen-plus","choices":[{"index":0,"delta":{"role":"assistant","reasoning_content":"need "}}]}
+
+data: {"id":"chatcmpl-1","choices":[{"index":0,"delta":{"reasoning_content":"a tool"}}]}
+
+data: {"id":"chatcmpl-1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"calculator","arguments":"{\"expr"}}]}}]}
+
+: keep-alive
+
+data: {"id":"chatcmpl-1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"ession\":\"1+2\"}"}}]}}]}
+
+data: {"id":"chatcmpl-1","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}
+
+data: {"id":"chatcmpl-1","choices":[],"usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15}}
+
+data: [DONE]
+
+`
+
+func TestClientInvokeStream_AssemblesToolCalls(t *testing.T) {
+	var gotReq ChatCompletionRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &gotReq)
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, sseToolCallStream)
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL, "test-key", 5*time.Second)
+	var (
+		reasoning strings.Builder
+		args      strings.Builder
+	)
+	invoke, err := client.InvokeStream(context.Background(), ChatCompletionRequest{
+		Model:    "qwen-plus",
+		Messages: []Message{{Role: "user", Content: "1+2?"}},
+	}, func(d StreamDelta) {
+		reasoning.WriteString(d.ReasoningContent)
+		for _, tc := range d.ToolCalls {
+			args.WriteString(tc.Function.Arguments)
+		}
+	})
+	if err != nil {
+		t.Fatalf("InvokeStream error: %v", err)
+	}
+	if !gotReq.Stream || gotReq.StreamOptions == nil || !gotReq.StreamOptions.IncludeUsage {
+		t.Fatalf("request stream flags not set: %+v", gotReq)
+	}
+	if reasoning.String() != "need a tool" {
+		t.Fatalf("streamed reasoning = %q", reasoning.String())
+	}
+	if args.String() != `{"expression":"1+2"}` {
+		t.Fatalf("streamed args = %q", args.String())
+	}
+
+	choice := invoke.Response.Choices[0]
+	if choice.FinishReason != "tool_calls" {
+		t.Fatalf("finish = %q, want tool_calls", choice.FinishReason)
+	}
+	msg := choice.Message
+	if msg.Role != "assistant" || msg.ReasoningContent != "need a tool" {
+		t.Fatalf("message = %+v", msg)
+	}
+	if len(msg.ToolCalls) != 1 || msg.ToolCalls[0].ID != "call_1" || msg.ToolCalls[0].Function.Name != "calculator" || msg.ToolCalls[0].Function.Arguments != `{"expression":"1+2"}` {
+		t.Fatalf("tool calls = %+v", msg.ToolCalls)
+	}
+	if invoke.Response.Usage == nil || invoke.Response.Usage.TotalTokens != 15 {
+		t.Fatalf("usage = %+v", invoke.Response.Usage)
+	}
+	if invoke.Response.ID != "chatcmpl-1" || invoke.Response.Model != "qwen-plus" {
+		t.Fatalf("response meta = %+v", invoke.Response)
+	}
+	if out := RenderInvokeResult(invoke); !strings.Contains(out, "calculator (id=call_1)") {
+		t.Fatalf("render missing tool call:\n%s", out)
+	}
+}
+
+func TestClientInvokeStream_HTTPError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":{"message":"bad key"}}`, http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	client := NewClient(srv.URL, "test-key", 5*time.Second)
+	invoke, err := client.InvokeStream(context.Background(), ChatCompletionRequest{Model: "qwen-plus"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "http 401") {
+		t.Fatalf("err = %v, want http 401", err)
+	}
+	if invoke == nil || invoke.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("invoke = %+v", invoke)
+	}
+}
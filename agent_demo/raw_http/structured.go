@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+type ResponseFormat struct {
+	Type       string            `json:"type"`
+	JSONSchema *JSONSchemaFormat `json:"json_schema,omitempty"`
+}
+
+type JSONSchemaFormat struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Schema      map[string]interface{} `json:"schema"`
+	Strict      bool                   `json:"strict,omitempty"`
+}
+
+// JSONObjectFormat asks for any valid JSON object. DashScope requires the
+// word "JSON" to appear in the prompt in this mode.
+func JSONObjectFormat() *ResponseFormat {
+	return &ResponseFormat{Type: "json_object"}
+}
+
+func JSONSchemaResponseFormat(name string, schema map[string]interface{}, strict bool) *ResponseFormat {
+	return &ResponseFormat{
+		Type:       "json_schema",
+		JSONSchema: &JSONSchemaFormat{Name: name, Schema: schema, Strict: strict},
+	}
+}
+
+func WithResponseFormat(f *ResponseFormat) ReACTOption {
+	return func(c *reactConfig) { c.responseFormat = f }
+}
+
+var ErrStructuredOutput = errors.New("model output does not match the schema")
+
+const maxStructuredAttempts = 3
+
+// ChatJSON runs a turn with response_format json_schema derived from out's
+// type, validates the answer against that schema and decodes it into out.
+// When the answer does not conform, the validation error is sent back as a
+// follow-up turn, up to three attempts in total; the failed attempts stay in
+// the history. out must be a non-nil pointer.
+func (s *Session) ChatJSON(ctx context.Context, prompt string, out interface{}) error {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return fmt.Errorf("ChatJSON: out must be a non-nil pointer, got %T", out)
+	}
+	t := rv.Type().Elem()
+	schema, err := schemaForType(t, map[reflect.Type]bool{})
+	if err != nil {
+		return fmt.Errorf("ChatJSON: %w", err)
+	}
+	name := sanitizeToolName(t.Name())
+	if name == "" {
+		name = "response"
+	}
+	format := WithResponseFormat(JSONSchemaResponseFormat(name, schema, false))
+
+	var lastErr error
+	for attempt := 0; attempt < maxStructuredAttempts; attempt++ {
+		final, err := s.chat(ctx, UserMessage(strings.TrimSpace(prompt)), format)
+		if err != nil {
+			return err
+		}
+		content := stripJSONFence(final)
+		if lastErr = ValidateToolArguments(schema, content); lastErr == nil {
+			if lastErr = json.Unmarshal([]byte(content), out); lastErr == nil {
+				return nil
+			}
+		}
+		prompt = fmt.Sprintf("你上一条回复不符合要求的 JSON schema：%v\n请只输出一个符合 schema 的 JSON，不要包含任何其他文字。", lastErr)
+	}
+	return fmt.Errorf("%w after %d attempts: %v", ErrStructuredOutput, maxStructuredAttempts, lastErr)
+}
+
+// ChatJSONAs is the generic form of Session.ChatJSON.
+func ChatJSONAs[T any](ctx context.Context, s *Session, prompt string) (T, error) {
+	var out T
+	err := s.ChatJSON(ctx, prompt, &out)
+	return out, err
+}
+
+// stripJSONFence removes a ```json ... ``` wrapper some models add even in
+// JSON mode.
+func stripJSONFence(s string) string {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "```") {
+		return s
+	}
+	s = strings.TrimPrefix(s, "```")
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[i+1:]
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s), "```"))
+}
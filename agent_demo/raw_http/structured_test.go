@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type cityInfo struct {
+	City       string   `json:"city" required:"true"`
+	Population int      `json:"population" required:"true"`
+	Tags       []string `json:"tags,omitempty"`
+}
+
+func TestSession_Offline_ChatJSON(t *testing.T) {
+	srv := newFakeServer(t,
+		fakeText(`{"city":"Hangzhou","population":"twelve million"}`),
+		fakeText("```json\n{\"city\":\"Hangzhou\",\"population\":12000000,\"tags\":[\"lake\"]}\n```"),
+	)
+	sess := NewSessionWithClient(srv.APIClient(), "qwen-plus")
+
+	var out cityInfo
+	if err := sess.ChatJSON(context.Background(), "Describe Hangzhou as JSON.", &out); err != nil {
+		t.Fatalf("ChatJSON error: %v", err)
+	}
+	if out.City != "Hangzhou" || out.Population != 12000000 || len(out.Tags) != 1 {
+		t.Fatalf("out = %+v", out)
+	}
+
+	reqs := srv.Requests()
+	rf := reqs[0].Req.ResponseFormat
+	if rf == nil || rf.Type != "json_schema" || rf.JSONSchema.Name != "cityInfo" || rf.JSONSchema.Schema["required"] == nil {
+		t.Fatalf("response_format = %+v", rf)
+	}
+	retry := reqs[1].Req.Messages
+	feedback := retry[len(retry)-1]
+	if feedback.Role != "user" || !strings.Contains(feedback.Content, "$.population: expected integer, got string") {
+		t.Fatalf("retry prompt = %+v", feedback)
+	}
+	if len(sess.Turns()) != 2 {
+		t.Fatalf("turns = %d", len(sess.Turns()))
+	}
+
+	// A plain Chat afterwards does not keep the response format.
+	srv.Push(fakeText("ok"))
+	if _, err := sess.Chat(context.Background(), "thanks"); err != nil {
+		t.Fatal(err)
+	}
+	if rf := srv.Requests()[2].Req.ResponseFormat; rf != nil {
+		t.Fatalf("response_format leaked into the next turn: %+v", rf)
+	}
+}
+
+func TestChatJSONAs_Offline_GivesUp(t *testing.T) {
+	srv := newFakeServer(t, fakeText("not json"), fakeText(`{"city":1}`), fakeText(`{}`))
+	sess := NewSessionWithClient(srv.APIClient(), "qwen-plus")
+
+	_, err := ChatJSONAs[cityInfo](context.Background(), sess, "JSON please")
+	if !errors.Is(err, ErrStructuredOutput) || !strings.Contains(err.Error(), "after 3 attempts") || !strings.Contains(err.Error(), "$.city: required property is missing") {
+		t.Fatalf("err = %v", err)
+	}
+	if len(srv.Requests()) != 3 {
+		t.Fatalf("requests = %d", len(srv.Requests()))
+	}
+
+	if err := sess.ChatJSON(context.Background(), "x", cityInfo{}); err == nil || !strings.Contains(err.Error(), "non-nil pointer") {
+		t.Fatalf("non-pointer err = %v", err)
+	}
+}
+
+func TestResponseFormatEncoding(t *testing.T) {
+	req := ChatCompletionRequest{Model: "qwen-plus", ResponseFormat: JSONObjectFormat()}
+	body, err := OpenAIProvider{}.EncodeRequest(req)
+	if err != nil || !strings.Contains(string(body), `"response_format":{"type":"json_object"}`) {
+		t.Fatalf("body = %s, err = %v", body, err)
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ToolChoice is the tool_choice union: a mode string ("auto", "none",
+// "required") or, when Function is set, the object form that forces one
+// named function: {"type":"function","function":{"name":...}}.
+type ToolChoice struct {
+	Mode     string
+	Function string
+}
+
+func ToolChoiceAuto() *ToolChoice     { return &ToolChoice{Mode: "auto"} }
+func ToolChoiceNone() *ToolChoice     { return &ToolChoice{Mode: "none"} }
+func ToolChoiceRequired() *ToolChoice { return &ToolChoice{Mode: "required"} }
+
+func ToolChoiceFunction(name string) *ToolChoice {
+	return &ToolChoice{Function: name}
+}
+
+// ParseToolChoice reads "auto", "none", "required", or anything else as
+// the name of the function to force.
+func ParseToolChoice(s string) (*ToolChoice, error) {
+	switch s = strings.TrimSpace(s); s {
+	case "":
+		return nil, nil
+	case "auto", "none", "required":
+		return &ToolChoice{Mode: s}, nil
+	}
+	if !toolNamePattern.MatchString(s) {
+		return nil, fmt.Errorf("invalid tool_choice %q", s)
+	}
+	return ToolChoiceFunction(s), nil
+}
+
+func (c *ToolChoice) String() string {
+	switch {
+	case c == nil:
+		return ""
+	case c.Function != "":
+		return "function:" + c.Function
+	}
+	return c.Mode
+}
+
+type toolChoiceObject struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name string `json:"name"`
+	} `json:"function"`
+}
+
+func (c ToolChoice) MarshalJSON() ([]byte, error) {
+	if c.Function == "" {
+		return json.Marshal(c.Mode)
+	}
+	obj := toolChoiceObject{Type: "function"}
+	obj.Function.Name = c.Function
+	return json.Marshal(obj)
+}
+
+func (c *ToolChoice) UnmarshalJSON(b []byte) error {
+	if b = bytes.TrimSpace(b); len(b) > 0 && b[0] == '"' {
+		*c = ToolChoice{}
+		return json.Unmarshal(b, &c.Mode)
+	}
+	var obj toolChoiceObject
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return fmt.Errorf("decode tool_choice: %w", err)
+	}
+	if obj.Type != "function" || obj.Function.Name == "" {
+		return fmt.Errorf("decode tool_choice: unsupported object %s", b)
+	}
+	*c = ToolChoice{Function: obj.Function.Name}
+	return nil
+}
+
+// ToolChoiceStrategy picks the tool_choice for a ReACT step (1-based) out of
+// maxSteps. Returning nil sends no tool_choice. Without WithToolChoice every
+// step with tools uses "auto".
+type ToolChoiceStrategy func(step, maxSteps int) *ToolChoice
+
+func WithToolChoice(strategy ToolChoiceStrategy) ReACTOption {
+	return func(c *reactConfig) { c.toolChoice = strategy }
+}
+
+func FixedToolChoice(choice *ToolChoice) ToolChoiceStrategy {
+	return func(step, maxSteps int) *ToolChoice { return choice }
+}
+
+// ToolChoicePerStep uses first on step 1, last on the final step and rest on
+// every other step; a nil first or last falls back to rest, and a nil rest
+// means "auto". ToolChoicePerStep(ToolChoiceRequired(), nil, ToolChoiceNone())
+// forces a tool call up front and a plain answer at the end.
+func ToolChoicePerStep(first, rest, last *ToolChoice) ToolChoiceStrategy {
+	if rest == nil {
+		rest = ToolChoiceAuto()
+	}
+	return func(step, maxSteps int) *ToolChoice {
+		switch {
+		case step == 1 && first != nil:
+			return first
+		case step == maxSteps && last != nil:
+			return last
+		}
+		return rest
+	}
+}
+
+// forces reports whether c makes the model call a tool.
+func (c *ToolChoice) forces() bool {
+	return c != nil && (c.Function != "" || c.Mode == "required")
+}
+
+// ParseToolChoiceStrategy reads "first[,rest[,last]]", each part as in
+// ParseToolChoice; empty parts fall back as in ToolChoicePerStep, e.g.
+// "required,auto,none" or ",,none". A single "auto" or "none" applies to
+// every step, while a single forcing value ("required" or a tool name) only
+// applies to step 1 with "auto" afterwards; forcing every step would leave
+// the model no way to answer.
+func ParseToolChoiceStrategy(spec string) (ToolChoiceStrategy, error) {
+	parts := strings.Split(spec, ",")
+	if len(parts) > 3 {
+		return nil, fmt.Errorf("tool choice %q: want first[,rest[,last]]", spec)
+	}
+	var choices [3]*ToolChoice
+	for i, p := range parts {
+		c, err := ParseToolChoice(p)
+		if err != nil {
+			return nil, err
+		}
+		choices[i] = c
+	}
+	if len(parts) == 1 && !choices[0].forces() {
+		return FixedToolChoice(choices[0]), nil
+	}
+	return ToolChoicePerStep(choices[0], choices[1], choices[2]), nil
+}
+
+// toolChoiceFor resolves the tool_choice of one step and checks that a
+// forced function is among the tools offered.
+func (c *reactConfig) toolChoiceFor(step, maxSteps int, tools []Tool) (*ToolChoice, error) {
+	if len(tools) == 0 {
+		return nil, nil
+	}
+	if c.toolChoice == nil {
+		return ToolChoiceAuto(), nil
+	}
+	choice := c.toolChoice(step, maxSteps)
+	if choice == nil || choice.Function == "" {
+		return choice, nil
+	}
+	for _, t := range tools {
+		if t.Function.Name == choice.Function {
+			return choice, nil
+		}
+	}
+	return nil, fmt.Errorf("tool_choice at step %d forces unknown tool %q", step, choice.Function)
+}
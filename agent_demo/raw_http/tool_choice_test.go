@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestToolChoice_JSON(t *testing.T) {
+	cases := map[string]*ToolChoice{
+		`"required"`: ToolChoiceRequired(),
+		`{"type":"function","function":{"name":"now"}}`: ToolChoiceFunction("now"),
+	}
+	for want, c := range cases {
+		b, err := json.Marshal(c)
+		if err != nil || string(b) != want {
+			t.Fatalf("marshal %v = %s, %v", c, b, err)
+		}
+		var back ToolChoice
+		if err := json.Unmarshal(b, &back); err != nil || back != *c {
+			t.Fatalf("unmarshal %s = %+v, %v", b, back, err)
+		}
+	}
+	var bad ToolChoice
+	if err := json.Unmarshal([]byte(`{"type":"tool","name":"now"}`), &bad); err == nil {
+		t.Fatalf("expected error for unsupported object")
+	}
+}
+
+func TestParseToolChoiceStrategy(t *testing.T) {
+	s, err := ParseToolChoiceStrategy("required,,none")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for step := 1; step <= 4; step++ {
+		got = append(got, s(step, 4).String())
+	}
+	if strings.Join(got, ",") != "required,auto,auto,none" {
+		t.Fatalf("steps = %v", got)
+	}
+	if s, _ := ParseToolChoiceStrategy("now"); s(1, 4).String() != "function:now" || s(2, 4).String() != "auto" || s(4, 4).String() != "auto" {
+		t.Fatalf("single forcing value = %v, %v, %v", s(1, 4), s(2, 4), s(4, 4))
+	}
+	if s, _ := ParseToolChoiceStrategy("none"); s(1, 4).String() != "none" || s(4, 4).String() != "none" {
+		t.Fatalf("single none = %v, %v", s(1, 4), s(4, 4))
+	}
+	if _, err := ParseToolChoiceStrategy("a,b,c,d"); err == nil {
+		t.Fatalf("expected error for four parts")
+	}
+	if _, err := ParseToolChoiceStrategy("bad name"); err == nil {
+		t.Fatalf("expected error for invalid name")
+	}
+}
+
+func TestDoReACT_Offline_ToolChoicePerStep(t *testing.T) {
+	srv := newFakeServer(t,
+		fakeToolCalls(toolCall("call_1", "now", `{}`)),
+		fakeToolCalls(toolCall("call_2", "calculator", `{"expression":"1+1"}`)),
+		fakeText("done"),
+	)
+	tools, handlers := BuiltinTools()
+	strategy := func(step, maxSteps int) *ToolChoice {
+		if step == 2 {
+			return ToolChoiceFunction("calculator")
+		}
+		return ToolChoicePerStep(ToolChoiceRequired(), nil, ToolChoiceNone())(step, maxSteps)
+	}
+
+	res, err := doReACT(context.Background(), srv.APIClient(), "qwen-plus", "sys", "go", tools, handlers, 0, 3, WithToolChoice(strategy))
+	if err != nil || res.Final != "done" {
+		t.Fatalf("res = %+v, err = %v", res, err)
+	}
+	reqs := srv.Requests()
+	for i, want := range []string{`"tool_choice":"required"`, `"tool_choice":{"type":"function","function":{"name":"calculator"}}`, `"tool_choice":"none"`} {
+		if !strings.Contains(string(reqs[i].Body), want) {
+			t.Fatalf("request %d missing %s: %s", i+1, want, reqs[i].Body)
+		}
+	}
+
+	_, err = doReACT(context.Background(), srv.APIClient(), "qwen-plus", "sys", "go", tools, handlers, 0, 3, WithToolChoice(FixedToolChoice(ToolChoiceFunction("missing"))))
+	if err == nil || !strings.Contains(err.Error(), `unknown tool "missing"`) || len(srv.Requests()) != 3 {
+		t.Fatalf("err = %v, requests = %d", err, len(srv.Requests()))
+	}
+}
+
+func TestAnthropicProvider_ForcedToolChoice(t *testing.T) {
+	req := ChatCompletionRequest{
+		Model:      "claude-sonnet-4-5",
+		Messages:   []Message{{Role: "user", Content: "hi"}},
+		Tools:      []Tool{{Type: "function", Function: ToolFunction{Name: "now"}}},
+		ToolChoice: ToolChoiceFunction("now"),
+	}
+	body, err := (&AnthropicProvider{MaxTokens: 1024}).EncodeRequest(req)
+	if err != nil || !strings.Contains(string(body), `"tool_choice":{"type":"tool","name":"now"}`) {
+		t.Fatalf("body = %s, err = %v", body, err)
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ToolNamespaceSep joins namespace segments in tool names, e.g.
+// "mcp__doris__get_db_table_list".
+const ToolNamespaceSep = "__"
+
+var toolNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+func NamespacedToolName(parts ...string) string {
+	return strings.Join(parts, ToolNamespaceSep)
+}
+
+// SplitToolName splits off the last namespace segment:
+// "mcp__doris__list" -> ("mcp__doris", "list").
+func SplitToolName(name string) (namespace, tool string) {
+	i := strings.LastIndex(name, ToolNamespaceSep)
+	if i <= 0 {
+		return "", name
+	}
+	return name[:i], name[i+len(ToolNamespaceSep):]
+}
+
+type registeredTool struct {
+	tool     Tool
+	handler  ToolHandler
+	disabled bool
+}
+
+// ToolRegistry keeps tool definitions and their handlers together so they
+// cannot drift apart. Tools() and Handlers() return snapshots of the enabled
+// tools and can be passed anywhere a []Tool / map[string]ToolHandler pair is
+// expected.
+type ToolRegistry struct {
+	mu      sync.RWMutex
+	order   []string
+	entries map[string]*registeredTool
+}
+
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{entries: map[string]*registeredTool{}}
+}
+
+// NewToolRegistryFrom builds a registry from an existing pair, failing if a
+// tool has no handler or a handler has no tool. It accepts BuiltinTools()
+// directly: NewToolRegistryFrom(BuiltinTools()).
+func NewToolRegistryFrom(tools []Tool, handlers map[string]ToolHandler) (*ToolRegistry, error) {
+	r := NewToolRegistry()
+	if err := r.RegisterAll(tools, handlers); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *ToolRegistry) Register(tool Tool, handler ToolHandler) error {
+	return r.RegisterAll([]Tool{tool}, map[string]ToolHandler{tool.Function.Name: handler})
+}
+
+// RegisterAll registers every tool in tools with its handler from handlers.
+// Nothing is registered if any entry is invalid.
+func (r *ToolRegistry) RegisterAll(tools []Tool, handlers map[string]ToolHandler) error {
+	return r.register("", tools, handlers)
+}
+
+// RegisterNamespace registers tools under namespace, so "list" in namespace
+// "mcp__doris" is exposed to the model as "mcp__doris__list". handlers are
+// keyed by the original, un-prefixed names.
+func (r *ToolRegistry) RegisterNamespace(namespace string, tools []Tool, handlers map[string]ToolHandler) error {
+	if strings.TrimSpace(namespace) == "" {
+		return fmt.Errorf("empty tool namespace")
+	}
+	return r.register(namespace, tools, handlers)
+}
+
+func (r *ToolRegistry) register(namespace string, tools []Tool, handlers map[string]ToolHandler) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	pending := make([]*registeredTool, 0, len(tools))
+	seen := make(map[string]bool, len(tools))
+	for _, t := range tools {
+		local := t.Function.Name
+		name := local
+		if namespace != "" {
+			name = NamespacedToolName(namespace, local)
+		}
+		if !toolNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid tool name %q: must match %s", name, toolNamePattern)
+		}
+		if _, dup := r.entries[name]; dup || seen[name] {
+			return fmt.Errorf("tool %q already registered", name)
+		}
+		h := handlers[local]
+		if h == nil {
+			return fmt.Errorf("tool %q has no handler", name)
+		}
+		if _, err := normalizeSchema(t.Function.Parameters); err != nil {
+			return fmt.Errorf("tool %q: invalid parameters schema: %w", name, err)
+		}
+		if t.Type == "" {
+			t.Type = "function"
+		}
+		t.Function.Name = name
+		seen[local] = true
+		seen[name] = true
+		pending = append(pending, &registeredTool{tool: t, handler: h})
+	}
+
+	var orphans []string
+	for local := range handlers {
+		if !seen[local] {
+			orphans = append(orphans, local)
+		}
+	}
+	if len(orphans) > 0 {
+		sort.Strings(orphans)
+		return fmt.Errorf("handlers without tool definitions: %s", strings.Join(orphans, ", "))
+	}
+
+	for _, e := range pending {
+		r.entries[e.tool.Function.Name] = e
+		r.order = append(r.order, e.tool.Function.Name)
+	}
+	return nil
+}
+
+func (r *ToolRegistry) Unregister(patterns ...string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	kept := r.order[:0]
+	removed := 0
+	for _, name := range r.order {
+		if matchToolPatterns(patterns, name) {
+			delete(r.entries, name)
+			removed++
+			continue
+		}
+		kept = append(kept, name)
+	}
+	r.order = kept
+	return removed
+}
+
+// Enable and Disable toggle tools by name; a trailing "*" matches a prefix,
+// e.g. "mcp__doris__*". Disabled tools stay registered but are left out of
+// Tools() and Handlers(), so the change applies from the next turn on.
+// Both return the number of tools matched.
+func (r *ToolRegistry) Enable(patterns ...string) int { return r.setDisabled(false, patterns) }
+
+func (r *ToolRegistry) Disable(patterns ...string) int { return r.setDisabled(true, patterns) }
+
+// Only enables exactly the tools matching patterns and disables the rest.
+func (r *ToolRegistry) Only(patterns ...string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, name := range r.order {
+		match := matchToolPatterns(patterns, name)
+		r.entries[name].disabled = !match
+		if match {
+			n++
+		}
+	}
+	return n
+}
+
+func (r *ToolRegistry) setDisabled(disabled bool, patterns []string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, name := range r.order {
+		if matchToolPatterns(patterns, name) {
+			r.entries[name].disabled = disabled
+			n++
+		}
+	}
+	return n
+}
+
+func matchToolPatterns(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if p == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *ToolRegistry) Enabled(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	e, ok := r.entries[name]
+	return ok && !e.disabled
+}
+
+func (r *ToolRegistry) Lookup(name string) (Tool, ToolHandler, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	e, ok := r.entries[name]
+	if !ok {
+		return Tool{}, nil, false
+	}
+	return e.tool, e.handler, true
+}
+
+// Names lists every registered tool, enabled or not, in registration order.
+func (r *ToolRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]string(nil), r.order...)
+}
+
+func (r *ToolRegistry) Tools() []Tool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var out []Tool
+	for _, name := range r.order {
+		if e := r.entries[name]; !e.disabled {
+			out = append(out, e.tool)
+		}
+	}
+	return out
+}
+
+func (r *ToolRegistry) Handlers() map[string]ToolHandler {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make(map[string]ToolHandler, len(r.order))
+	for _, name := range r.order {
+		if e := r.entries[name]; !e.disabled {
+			out[name] = e.handler
+		}
+	}
+	return out
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func echoTool(name string) (Tool, ToolHandler) {
+	return Tool{Type: "function", Function: ToolFunction{Name: name}},
+		func(ctx context.Context, args json.RawMessage) (string, error) { return name, nil }
+}
+
+func TestToolRegistry_RejectsMismatches(t *testing.T) {
+	now, nowHandler := NowTool()
+	cases := []struct {
+		name     string
+		tools    []Tool
+		handlers map[string]ToolHandler
+		want     string
+	}{
+		{"missing handler", []Tool{now}, nil, `tool "now" has no handler`},
+		{"orphan handler", []Tool{now}, map[string]ToolHandler{"now": nowHandler, "ghost": nowHandler}, "handlers without tool definitions: ghost"},
+		{"duplicate in batch", []Tool{now, now}, map[string]ToolHandler{"now": nowHandler}, `tool "now" already registered`},
+		{"bad name", []Tool{{Function: ToolFunction{Name: "has space"}}}, map[string]ToolHandler{"has space": nowHandler}, "invalid tool name"},
+		{"bad schema", []Tool{{Function: ToolFunction{Name: "x", Parameters: map[string]interface{}{"f": func() {}}}}}, map[string]ToolHandler{"x": nowHandler}, "invalid parameters schema"},
+	}
+	for _, c := range cases {
+		r := NewToolRegistry()
+		err := r.RegisterAll(c.tools, c.handlers)
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Fatalf("%s: err = %v, want %q", c.name, err, c.want)
+		}
+		if len(r.Names()) != 0 {
+			t.Fatalf("%s: partial registration %v", c.name, r.Names())
+		}
+	}
+
+	r, err := NewToolRegistryFrom(BuiltinTools())
+	if err != nil {
+		t.Fatalf("NewToolRegistryFrom: %v", err)
+	}
+	if err := r.Register(now, nowHandler); err == nil || !strings.Contains(err.Error(), "already registered") {
+		t.Fatalf("duplicate err = %v", err)
+	}
+}
+
+func TestToolRegistry_NamespaceAndToggle(t *testing.T) {
+	r := NewToolRegistry()
+	if err := r.RegisterAll(BuiltinTools()); err != nil {
+		t.Fatal(err)
+	}
+	listTool, listHandler := echoTool("get_db_table_list")
+	descTool, descHandler := echoTool("describe_table")
+	if err := r.RegisterNamespace(NamespacedToolName("mcp", "doris"), []Tool{listTool, descTool}, map[string]ToolHandler{
+		"get_db_table_list": listHandler,
+		"describe_table":    descHandler,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "now,calculator,mcp__doris__get_db_table_list,mcp__doris__describe_table"
+	if got := strings.Join(r.Names(), ","); got != want {
+		t.Fatalf("names = %s", got)
+	}
+	if ns, name := SplitToolName("mcp__doris__get_db_table_list"); ns != "mcp__doris" || name != "get_db_table_list" {
+		t.Fatalf("split = %q %q", ns, name)
+	}
+	if out, _ := r.Handlers()["mcp__doris__describe_table"](context.Background(), nil); out != "describe_table" {
+		t.Fatalf("namespaced handler = %q", out)
+	}
+
+	if n := r.Disable("mcp__doris__*"); n != 2 {
+		t.Fatalf("disabled %d", n)
+	}
+	if got := toolNames(r.Tools()); got != "now,calculator" {
+		t.Fatalf("tools after disable = %s", got)
+	}
+	if _, ok := r.Handlers()["mcp__doris__describe_table"]; ok {
+		t.Fatalf("disabled handler still exposed")
+	}
+	if n := r.Only("calculator", "mcp__doris__describe_table"); n != 2 {
+		t.Fatalf("only matched %d", n)
+	}
+	if got := toolNames(r.Tools()); got != "calculator,mcp__doris__describe_table" {
+		t.Fatalf("tools after only = %s", got)
+	}
+	if n := r.Unregister("mcp__*"); n != 2 || len(r.Names()) != 2 {
+		t.Fatalf("unregister = %d, names = %v", n, r.Names())
+	}
+}
+
+func toolNames(tools []Tool) string {
+	var names []string
+	for _, t := range tools {
+		names = append(names, t.Function.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestSession_Offline_ToolRegistryPerTurn(t *testing.T) {
+	srv := newFakeServer(t,
+		fakeToolCalls(toolCall("call_1", "calculator", `{"expression":"1+1"}`)),
+		fakeText("2"),
+		fakeText("no tools"),
+	)
+	r, err := NewToolRegistryFrom(BuiltinTools())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess := NewSessionWithClient(srv.APIClient(), "qwen-plus")
+	sess.UseToolRegistry(r)
+
+	if _, err := sess.Chat(context.Background(), "1+1?"); err != nil {
+		t.Fatalf("turn 1: %v", err)
+	}
+	r.Disable("*")
+	if _, err := sess.Chat(context.Background(), "hi"); err != nil {
+		t.Fatalf("turn 2: %v", err)
+	}
+
+	reqs := srv.Requests()
+	if got := toolNames(reqs[0].Req.Tools); got != "now,calculator" {
+		t.Fatalf("turn 1 tools = %s", got)
+	}
+	if len(reqs[2].Req.Tools) != 0 || reqs[2].Req.ToolChoice != nil {
+		t.Fatalf("turn 2 request = %+v", reqs[2].Req)
+	}
+	if st := sess.State(); len(st.Tools) != 0 {
+		t.Fatalf("state tools = %v", st.Tools)
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultNowFormat = "2006-01-02 15:04:05 MST"
+
+var nowFunc = time.Now
+
+func BuiltinTools() ([]Tool, map[string]ToolHandler) {
+	nowTool, nowHandler := NowTool()
+	calcTool, calcHandler := CalculatorTool()
+	return []Tool{nowTool, calcTool}, map[string]ToolHandler{
+		nowTool.Function.Name:  nowHandler,
+		calcTool.Function.Name: calcHandler,
+	}
+}
+
+func NowTool() (Tool, ToolHandler) {
+	tool := Tool{
+		Type: "function",
+		Function: ToolFunction{
+			Name:        "now",
+			Description: "Get the current time.",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"timezone": map[string]interface{}{
+						"type":        "string",
+						"description": "IANA time zone, e.g. Asia/Shanghai. Defaults to local time.",
+					},
+					"format": map[string]interface{}{
+						"type":        "string",
+						"description": "Go time layout, e.g. 2006-01-02 15:04:05. Defaults to " + defaultNowFormat + ".",
+					},
+				},
+			},
+		},
+	}
+	handler := func(ctx context.Context, args json.RawMessage) (string, error) {
+		var in struct {
+			Timezone string `json:"timezone"`
+			Format   string `json:"format"`
+		}
+		if err := decodeToolArgs(args, &in); err != nil {
+			return "", err
+		}
+		now := nowFunc()
+		if tz := strings.TrimSpace(in.Timezone); tz != "" {
+			loc, err := time.LoadLocation(tz)
+			if err != nil {
+				return "", fmt.Errorf("unknown timezone %q", tz)
+			}
+			now = now.In(loc)
+		}
+		format := strings.TrimSpace(in.Format)
+		if format == "" {
+			format = defaultNowFormat
+		}
+		out, err := json.Marshal(map[string]string{
+			"time":     now.Format(format),
+			"timezone": now.Location().String(),
+		})
+		if err != nil {
+			return "", err
+		}
+		return string(out), nil
+	}
+	return tool, handler
+}
+
+func CalculatorTool() (Tool, ToolHandler) {
+	tool := Tool{
+		Type: "function",
+		Function: ToolFunction{
+			Name:        "calculator",
+			Description: "Evaluate an arithmetic expression with + - * / and parentheses.",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"expression": map[string]interface{}{
+						"type":        "string",
+						"description": "Expression to evaluate, e.g. (1+2)*3",
+					},
+				},
+				"required": []string{"expression"},
+			},
+		},
+	}
+	handler := func(ctx context.Context, args json.RawMessage) (string, error) {
+		var in struct {
+			Expression string `json:"expression"`
+		}
+		if err := decodeToolArgs(args, &in); err != nil {
+			return "", err
+		}
+		v, err := EvalExpression(in.Expression)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	}
+	return tool, handler
+}
+
+func decodeToolArgs(args json.RawMessage, v any) error {
+	if len(strings.TrimSpace(string(args))) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(args, v); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+	return nil
+}
+
+func EvalExpression(expr string) (float64, error) {
+	p := &exprParser{src: expr}
+	if strings.TrimSpace(expr) == "" {
+		return 0, errors.New("empty expression")
+	}
+	v, err := p.parseExpr()
+	if err != nil {
+		return 0, err
+	}
+	p.skipSpaces()
+	if p.pos < len(p.src) {
+		return 0, fmt.Errorf("unexpected %q at offset %d", p.src[p.pos], p.pos)
+	}
+	if math.IsInf(v, 0) || math.IsNaN(v) {
+		return 0, errors.New("result is not a finite number")
+	}
+	return v, nil
+}
+
+type exprParser struct {
+	src   string
+	pos   int
+	depth int
+}
+
+const maxExprDepth = 64
+
+func (p *exprParser) skipSpaces() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t' || p.src[p.pos] == '\n') {
+		p.pos++
+	}
+}
+
+func (p *exprParser) peek() byte {
+	p.skipSpaces()
+	if p.pos >= len(p.src) {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *exprParser) parseExpr() (float64, error) {
+	v, err := p.parseTerm()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		switch p.peek() {
+		case '+':
+			p.pos++
+			r, err := p.parseTerm()
+			if err != nil {
+				return 0, err
+			}
+			v += r
+		case '-':
+			p.pos++
+			r, err := p.parseTerm()
+			if err != nil {
+				return 0, err
+			}
+			v -= r
+		default:
+			return v, nil
+		}
+	}
+}
+
+func (p *exprParser) parseTerm() (float64, error) {
+	v, err := p.parseFactor()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		switch p.peek() {
+		case '*':
+			p.pos++
+			r, err := p.parseFactor()
+			if err != nil {
+				return 0, err
+			}
+			v *= r
+		case '/':
+			p.pos++
+			r, err := p.parseFactor()
+			if err != nil {
+				return 0, err
+			}
+			if r == 0 {
+				return 0, errors.New("division by zero")
+			}
+			v /= r
+		default:
+			return v, nil
+		}
+	}
+}
+
+func (p *exprParser) parseFactor() (float64, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxExprDepth {
+		return 0, errors.New("expression nested too deeply")
+	}
+
+	switch c := p.peek(); {
+	case c == '+':
+		p.pos++
+		return p.parseFactor()
+	case c == '-':
+		p.pos++
+		v, err := p.parseFactor()
+		return -v, err
+	case c == '(':
+		p.pos++
+		v, err := p.parseExpr()
+		if err != nil {
+			return 0, err
+		}
+		if p.peek() != ')' {
+			return 0, fmt.Errorf("missing ')' at offset %d", p.pos)
+		}
+		p.pos++
+		return v, nil
+	case c == '.' || (c >= '0' && c <= '9'):
+		start := p.pos
+		for p.pos < len(p.src) && (p.src[p.pos] == '.' || (p.src[p.pos] >= '0' && p.src[p.pos] <= '9')) {
+			p.pos++
+		}
+		v, err := strconv.ParseFloat(p.src[start:p.pos], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid number %q", p.src[start:p.pos])
+		}
+		return v, nil
+	case c == 0:
+		return 0, errors.New("unexpected end of expression")
+	default:
+		return 0, fmt.Errorf("unexpected %q at offset %d", c, p.pos)
+	}
+}
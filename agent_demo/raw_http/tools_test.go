@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestEvalExpression(t *testing.T) {
+	cases := []struct {
+		expr string
+		want float64
+	}{
+		{"1+2", 3},
+		{"(1+2)*3", 9},
+		{"2+3*4", 14},
+		{"10/4", 2.5},
+		{"-3 + 5", 2},
+		{"-(2+3)*2", -10},
+		{" 1.5 * 2 ", 3},
+		{"8/2/2", 2},
+		{"1-2-3", -4},
+	}
+	for _, c := range cases {
+		got, err := EvalExpression(c.expr)
+		if err != nil {
+			t.Fatalf("EvalExpression(%q) error: %v", c.expr, err)
+		}
+		if got != c.want {
+			t.Fatalf("EvalExpression(%q) = %v, want %v", c.expr, got, c.want)
+		}
+	}
+}
+
+func TestEvalExpression_Errors(t *testing.T) {
+	for _, expr := range []string{"", "1+", "(1+2", "1/0", "2*x", "1..2", "os.Exit(1)"} {
+		if _, err := EvalExpression(expr); err == nil {
+			t.Fatalf("EvalExpression(%q) expected error", expr)
+		}
+	}
+}
+
+func TestCalculatorTool(t *testing.T) {
+	tool, handler := CalculatorTool()
+	if tool.Function.Name != "calculator" {
+		t.Fatalf("name = %q, want calculator", tool.Function.Name)
+	}
+	out, err := handler(context.Background(), json.RawMessage(`{"expression":"(1+2)*3"}`))
+	if err != nil {
+		t.Fatalf("handler error: %v", err)
+	}
+	if out != "9" {
+		t.Fatalf("out = %q, want 9", out)
+	}
+}
+
+func TestNowTool(t *testing.T) {
+	orig := nowFunc
+	nowFunc = func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) }
+	defer func() { nowFunc = orig }()
+
+	_, handler := NowTool()
+	out, err := handler(context.Background(), json.RawMessage(`{"timezone":"Asia/Shanghai","format":"2006-01-02 15:04"}`))
+	if err != nil {
+		t.Fatalf("handler error: %v", err)
+	}
+	var got map[string]string
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatalf("decode %q: %v", out, err)
+	}
+	if got["time"] != "2024-01-02 11:04" || got["timezone"] != "Asia/Shanghai" {
+		t.Fatalf("got %v", got)
+	}
+
+	if _, err := handler(context.Background(), json.RawMessage(`{"timezone":"Mars/Olympus"}`)); err == nil {
+		t.Fatalf("expected error for unknown timezone")
+	}
+}
+
+func TestBuiltinTools(t *testing.T) {
+	tools, handlers := BuiltinTools()
+	if len(tools) != 2 {
+		t.Fatalf("len(tools) = %d, want 2", len(tools))
+	}
+	for _, tool := range tools {
+		if handlers[tool.Function.Name] == nil {
+			t.Fatalf("missing handler for %s", tool.Function.Name)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NewTypedTool builds a Tool whose parameters schema is derived from In and a
+// ToolHandler that decodes arguments into In and JSON-encodes Out (strings are
+// returned verbatim). In must be a struct; it panics otherwise, like
+// regexp.MustCompile, because that is a programming error.
+func NewTypedTool[In, Out any](name, desc string, fn func(ctx context.Context, in In) (Out, error)) (Tool, ToolHandler) {
+	schema, err := SchemaFor[In]()
+	if err != nil {
+		panic(fmt.Sprintf("NewTypedTool(%s): %v", name, err))
+	}
+	if schema["type"] != "object" {
+		panic(fmt.Sprintf("NewTypedTool(%s): input type must be a struct, got %s", name, reflect.TypeFor[In]()))
+	}
+
+	tool := Tool{
+		Type: "function",
+		Function: ToolFunction{
+			Name:        name,
+			Description: desc,
+			Parameters:  schema,
+		},
+	}
+	handler := func(ctx context.Context, args json.RawMessage) (string, error) {
+		var in In
+		if err := decodeToolArgs(args, &in); err != nil {
+			return "", err
+		}
+		out, err := fn(ctx, in)
+		if err != nil {
+			return "", err
+		}
+		if s, ok := any(out).(string); ok {
+			return s, nil
+		}
+		b, err := json.Marshal(out)
+		if err != nil {
+			return "", fmt.Errorf("encode result: %w", err)
+		}
+		return string(b), nil
+	}
+	return tool, handler
+}
+
+func SchemaFor[T any]() (map[string]interface{}, error) {
+	return schemaForType(reflect.TypeFor[T](), map[reflect.Type]bool{})
+}
+
+var (
+	timeType       = reflect.TypeFor[time.Time]()
+	rawMessageType = reflect.TypeFor[json.RawMessage]()
+)
+
+func schemaForType(t reflect.Type, visiting map[reflect.Type]bool) (map[string]interface{}, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}, nil
+	case rawMessageType:
+		return map[string]interface{}{}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}, nil
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "minimum": 0}, nil
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}, nil
+	case reflect.Interface:
+		return map[string]interface{}{}, nil
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "contentEncoding": "base64"}, nil
+		}
+		items, err := schemaForType(t.Elem(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "array", "items": items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("unsupported map key type %s", t.Key())
+		}
+		values, err := schemaForType(t.Elem(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "object", "additionalProperties": values}, nil
+	case reflect.Struct:
+		if visiting[t] {
+			return nil, fmt.Errorf("recursive type %s is not supported", t)
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		props := map[string]interface{}{}
+		var required []string
+		if err := collectStructFields(t, visiting, props, &required); err != nil {
+			return nil, err
+		}
+		out := map[string]interface{}{
+			"type":                 "object",
+			"properties":           props,
+			"additionalProperties": false,
+		}
+		if len(required) > 0 {
+			out["required"] = required
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("unsupported type %s", t)
+}
+
+func collectStructFields(t reflect.Type, visiting map[reflect.Type]bool, props map[string]interface{}, required *[]string) error {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			for ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if err := collectStructFields(ft, visiting, props, required); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		s, err := schemaForType(f.Type, visiting)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", f.Name, err)
+		}
+		if desc := f.Tag.Get("description"); desc != "" {
+			s["description"] = desc
+		}
+		if enum := f.Tag.Get("enum"); enum != "" {
+			values, err := parseEnumTag(enum, s["type"])
+			if err != nil {
+				return fmt.Errorf("field %s: %w", f.Name, err)
+			}
+			s["enum"] = values
+		}
+		if f.Type.Kind() == reflect.Pointer {
+			nullable(s)
+		}
+		if req, _ := strconv.ParseBool(f.Tag.Get("required")); req {
+			*required = append(*required, name)
+		}
+		props[name] = s
+	}
+	return nil
+}
+
+// nullable lets s also accept null, as json.Unmarshal does for pointer fields.
+func nullable(s map[string]interface{}) {
+	if t, ok := s["type"].(string); ok {
+		s["type"] = []interface{}{t, "null"}
+	}
+	if enum, ok := s["enum"].([]interface{}); ok {
+		s["enum"] = append(enum, nil)
+	}
+}
+
+func parseEnumTag(tag string, typ interface{}) ([]interface{}, error) {
+	var out []interface{}
+	for _, raw := range strings.Split(tag, ",") {
+		v := strings.TrimSpace(raw)
+		switch typ {
+		case "integer":
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("enum value %q is not an integer", v)
+			}
+			out = append(out, n)
+		case "number":
+			n, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil, fmt.Errorf("enum value %q is not a number", v)
+			}
+			out = append(out, n)
+		default:
+			out = append(out, v)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type weatherQuery struct {
+	Date     string   `json:"date" description:"Date in YYYY-MM-DD." required:"true"`
+	Location string   `json:"location" description:"City and state." required:"true"`
+	Unit     string   `json:"unit,omitempty" enum:"celsius,fahrenheit"`
+	Days     *int     `json:"days,omitempty" enum:"1,3,7"`
+	Tags     []string `json:"tags,omitempty"`
+	internal string
+}
+
+type weatherReport struct {
+	Weather     string `json:"weather"`
+	Temperature int    `json:"temperature"`
+	Unit        string `json:"unit"`
+}
+
+func TestSchemaFor_StructTags(t *testing.T) {
+	type Paging struct {
+		Limit uint `json:"limit"`
+	}
+	type query struct {
+		Paging
+		Name   string               `json:"name" required:"true"`
+		Filter map[string]bool      `json:"filter"`
+		Nested *struct{ X float64 } `json:"nested"`
+		Skip   string               `json:"-"`
+	}
+	got, err := SchemaFor[query]()
+	if err != nil {
+		t.Fatalf("SchemaFor error: %v", err)
+	}
+	b, _ := json.Marshal(got)
+	want := `{"additionalProperties":false,"properties":{` +
+		`"filter":{"additionalProperties":{"type":"boolean"},"type":"object"},` +
+		`"limit":{"minimum":0,"type":"integer"},` +
+		`"name":{"type":"string"},` +
+		`"nested":{"additionalProperties":false,"properties":{"X":{"type":"number"}},"type":["object","null"]}},` +
+		`"required":["name"],"type":"object"}`
+	if string(b) != want {
+		t.Fatalf("schema =\n%s\nwant\n%s", b, want)
+	}
+
+	ws, err := SchemaFor[weatherQuery]()
+	if err != nil {
+		t.Fatalf("SchemaFor error: %v", err)
+	}
+	props := ws["properties"].(map[string]interface{})
+	if _, ok := props["internal"]; ok {
+		t.Fatalf("unexported field leaked into schema")
+	}
+	if got := props["days"].(map[string]interface{})["enum"]; !reflect.DeepEqual(got, []interface{}{int64(1), int64(3), int64(7), nil}) {
+		t.Fatalf("days enum = %#v", got)
+	}
+	if got := props["date"].(map[string]interface{})["description"]; got != "Date in YYYY-MM-DD." {
+		t.Fatalf("date description = %v", got)
+	}
+}
+
+func TestSchemaFor_PointerFieldsAcceptNull(t *testing.T) {
+	type lookup struct {
+		ID   string  `json:"id" required:"true"`
+		Name *string `json:"name"`
+		Days *int    `json:"days" enum:"1,3,7"`
+	}
+	schema, err := SchemaFor[lookup]()
+	if err != nil {
+		t.Fatalf("SchemaFor error: %v", err)
+	}
+	props := schema["properties"].(map[string]interface{})
+	if got := props["name"].(map[string]interface{})["type"]; !reflect.DeepEqual(got, []interface{}{"string", "null"}) {
+		t.Fatalf("name type = %#v", got)
+	}
+
+	norm, err := normalizeSchema(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validateNormalizedArgs(norm, `{"id":"a","name":null,"days":null}`); err != nil {
+		t.Fatalf("null optional fields rejected: %v", err)
+	}
+	if err := validateNormalizedArgs(norm, `{"id":null,"days":2}`); err == nil || !strings.Contains(err.Error(), "$.id: expected string, got null") || !strings.Contains(err.Error(), "$.days: value 2") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestSchemaFor_Unsupported(t *testing.T) {
+	type node struct {
+		Next *node `json:"next"`
+	}
+	if _, err := SchemaFor[node](); err == nil || !strings.Contains(err.Error(), "recursive type") {
+		t.Fatalf("recursive err = %v", err)
+	}
+	if _, err := SchemaFor[struct{ C chan int }](); err == nil || !strings.Contains(err.Error(), "field C: unsupported type chan int") {
+		t.Fatalf("chan err = %v", err)
+	}
+	if _, err := SchemaFor[struct {
+		N int `enum:"a"`
+	}](); err == nil || !strings.Contains(err.Error(), "not an integer") {
+		t.Fatalf("enum err = %v", err)
+	}
+
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(r.(string), "must be a struct") {
+			t.Fatalf("recover = %v", r)
+		}
+	}()
+	NewTypedTool("bad", "", func(ctx context.Context, in string) (string, error) { return in, nil })
+}
+
+func TestNewTypedTool_Handler(t *testing.T) {
+	_, handler := NewTypedTool("get_weather", "", func(ctx context.Context, in weatherQuery) (weatherReport, error) {
+		if in.Location == "nowhere" {
+			return weatherReport{}, errors.New("unknown location")
+		}
+		return weatherReport{Weather: "sunny", Temperature: 20, Unit: in.Unit}, nil
+	})
+
+	out, err := handler(context.Background(), json.RawMessage(`{"date":"2024-01-02","location":"SF","unit":"celsius"}`))
+	if err != nil || out != `{"weather":"sunny","temperature":20,"unit":"celsius"}` {
+		t.Fatalf("out = %q, err = %v", out, err)
+	}
+	if _, err := handler(context.Background(), json.RawMessage(`{"date":"x","location":"nowhere"}`)); err == nil || err.Error() != "unknown location" {
+		t.Fatalf("err = %v", err)
+	}
+	if _, err := handler(context.Background(), json.RawMessage(`{"date":1}`)); err == nil {
+		t.Fatalf("expected decode error")
+	}
+
+	_, echo := NewTypedTool("echo", "", func(ctx context.Context, in struct {
+		Text string `json:"text"`
+	}) (string, error) {
+		return in.Text, nil
+	})
+	if out, err := echo(context.Background(), nil); err != nil || out != "" {
+		t.Fatalf("echo empty args = %q, %v", out, err)
+	}
+	if out, _ := echo(context.Background(), json.RawMessage(`{"text":"hi"}`)); out != "hi" {
+		t.Fatalf("echo = %q", out)
+	}
+}
+
+func TestDoReACT_Offline_TypedTools(t *testing.T) {
+	srv := newFakeServer(t,
+		fakeToolCalls(toolCall("call_1", "get_weather_by_date", `{"date":"2024-01-02","unit":"kelvin"}`)),
+		fakeToolCalls(toolCall("call_2", "get_weather_by_date", `{"date":"2024-01-02","location":"San Francisco, CA","unit":"celsius"}`)),
+		fakeText("sunny, 20C"),
+	)
+	var got []weatherQuery
+	tool, handler := NewTypedTool("get_weather_by_date", "Get weather by date and location.",
+		func(ctx context.Context, in weatherQuery) (weatherReport, error) {
+			got = append(got, in)
+			return weatherReport{Weather: "sunny", Temperature: 20, Unit: in.Unit}, nil
+		})
+
+	res, err := doReACT(context.Background(), srv.APIClient(), "qwen-plus", "sys", "weather?",
+		[]Tool{tool}, map[string]ToolHandler{tool.Function.Name: handler}, 0, 4)
+	if err != nil {
+		t.Fatalf("doReACT error: %v", err)
+	}
+	if res.Final != "sunny, 20C" {
+		t.Fatalf("final = %q", res.Final)
+	}
+	if len(got) != 1 || got[0].Location != "San Francisco, CA" || got[0].Unit != "celsius" {
+		t.Fatalf("handler calls = %+v", got)
+	}
+
+	first := srv.Requests()[0].Req
+	if len(first.Tools) != 1 || first.Tools[0].Function.Parameters["required"] == nil {
+		t.Fatalf("tools sent = %+v", first.Tools)
+	}
+	sent := srv.Requests()[1].Req.Messages
+	if fb := sent[len(sent)-1].Content; !strings.Contains(fb, "$.location: required property is missing") || !strings.Contains(fb, `$.unit: value "kelvin"`) {
+		t.Fatalf("validation feedback = %q", fb)
+	}
+	if out := srv.Requests()[2].Req.Messages; out[len(out)-1].Content != `{"weather":"sunny","temperature":20,"unit":"celsius"}` {
+		t.Fatalf("tool result = %q", out[len(out)-1].Content)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PromptTokensDetails breaks down prompt_tokens. CachedTokens were read from
+// the provider's prompt cache; CacheCreationTokens were written to it
+// (DashScope explicit cache and Anthropic report these).
+type PromptTokensDetails struct {
+	CachedTokens        int `json:"cached_tokens,omitempty"`
+	CacheCreationTokens int `json:"cache_creation_input_tokens,omitempty"`
+}
+
+type CompletionTokensDetails struct {
+	ReasoningTokens int `json:"reasoning_tokens,omitempty"`
+}
+
+func (u *Usage) CachedTokens() int {
+	if u == nil || u.PromptTokensDetails == nil {
+		return 0
+	}
+	return u.PromptTokensDetails.CachedTokens
+}
+
+func (u *Usage) CacheCreationTokens() int {
+	if u == nil || u.PromptTokensDetails == nil {
+		return 0
+	}
+	return u.PromptTokensDetails.CacheCreationTokens
+}
+
+func (u *Usage) ReasoningTokens() int {
+	if u == nil || u.CompletionTokensDetails == nil {
+		return 0
+	}
+	return u.CompletionTokensDetails.ReasoningTokens
+}
+
+// CacheHitRatio is the share of prompt tokens served from the cache.
+func (u *Usage) CacheHitRatio() float64 {
+	if u == nil || u.PromptTokens == 0 {
+		return 0
+	}
+	return float64(u.CachedTokens()) / float64(u.PromptTokens)
+}
+
+// Add accumulates o into u; the details are allocated only once a non-zero
+// value shows up, so an aggregate of plain usages encodes as before.
+func (u *Usage) Add(o *Usage) {
+	if o == nil {
+		return
+	}
+	u.PromptTokens += o.PromptTokens
+	u.CompletionTokens += o.CompletionTokens
+	u.TotalTokens += o.TotalTokens
+	if cached, created := o.CachedTokens(), o.CacheCreationTokens(); cached != 0 || created != 0 {
+		if u.PromptTokensDetails == nil {
+			u.PromptTokensDetails = &PromptTokensDetails{}
+		}
+		u.PromptTokensDetails.CachedTokens += cached
+		u.PromptTokensDetails.CacheCreationTokens += created
+	}
+	if r := o.ReasoningTokens(); r != 0 {
+		if u.CompletionTokensDetails == nil {
+			u.CompletionTokensDetails = &CompletionTokensDetails{}
+		}
+		u.CompletionTokensDetails.ReasoningTokens += r
+	}
+}
+
+// String renders "prompt=120 (cached=96, 80.0%), completion=30 (reasoning=12), total=150";
+// the parenthesised details appear only when non-zero.
+func (u *Usage) String() string {
+	if u == nil {
+		return ""
+	}
+	var prompt []string
+	if cached := u.CachedTokens(); cached > 0 {
+		prompt = append(prompt, fmt.Sprintf("cached=%d, %.1f%%", cached, 100*u.CacheHitRatio()))
+	}
+	if created := u.CacheCreationTokens(); created > 0 {
+		prompt = append(prompt, fmt.Sprintf("cache_write=%d", created))
+	}
+	s := fmt.Sprintf("prompt=%d", u.PromptTokens)
+	if len(prompt) > 0 {
+		s += " (" + strings.Join(prompt, ", ") + ")"
+	}
+	s += fmt.Sprintf(", completion=%d", u.CompletionTokens)
+	if r := u.ReasoningTokens(); r > 0 {
+		s += fmt.Sprintf(" (reasoning=%d)", r)
+	}
+	return s + fmt.Sprintf(", total=%d", u.TotalTokens)
+}
+
+// usageDetail is the ", cached=96 (80.0%), reasoning=12" suffix appended
+// after a token count in rendered lines; empty when there is nothing to add.
+func usageDetail(u *Usage) string {
+	var s string
+	if cached := u.CachedTokens(); cached > 0 {
+		s += fmt.Sprintf(", cached=%d (%.1f%%)", cached, 100*u.CacheHitRatio())
+	}
+	if r := u.ReasoningTokens(); r > 0 {
+		s += fmt.Sprintf(", reasoning=%d", r)
+	}
+	return s
+}
+
+// Usage sums the usage of every call made by the loop, including a forced
+// final answer.
+func (r *ReACTResult) Usage() Usage {
+	var total Usage
+	if r == nil {
+		return total
+	}
+	for _, inv := range r.Invokes {
+		if inv != nil {
+			total.Add(inv.Response.Usage)
+		}
+	}
+	return total
+}
+
+// Usage sums the usage recorded in the session's turns, so it survives
+// Save/Load and restarts at zero after Reset. Failed turns and compaction
+// summaries are not included.
+func (s *Session) Usage() Usage {
+	var total Usage
+	for _, t := range s.turns {
+		for _, inv := range t.Invokes {
+			total.Add(inv.Usage)
+		}
+	}
+	return total
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestUsage_DecodeDetails(t *testing.T) {
+	body := `{"id":"chatcmpl-1","choices":[{"index":0,"message":{"role":"assistant","content":"hi"},"finish_reason":"stop"}],
+		"usage":{"prompt_tokens":2000,"completion_tokens":300,"total_tokens":2300,
+			"prompt_tokens_details":{"cached_tokens":1600},
+			"completion_tokens_details":{"reasoning_tokens":120}}}`
+	var resp ChatCompletionResponse
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		t.Fatal(err)
+	}
+	u := resp.Usage
+	if u.CachedTokens() != 1600 || u.ReasoningTokens() != 120 || u.CacheHitRatio() != 0.8 {
+		t.Fatalf("usage = %s", u)
+	}
+	if got := u.String(); got != "prompt=2000 (cached=1600, 80.0%), completion=300 (reasoning=120), total=2300" {
+		t.Fatalf("String() = %q", got)
+	}
+
+	var plain Usage
+	plain.Add(&Usage{PromptTokens: 1, CompletionTokens: 1, TotalTokens: 2})
+	if b, _ := json.Marshal(plain); string(b) != `{"prompt_tokens":1,"completion_tokens":1,"total_tokens":2}` {
+		t.Fatalf("plain aggregate = %s", b)
+	}
+	var nilUsage *Usage
+	if nilUsage.CachedTokens() != 0 || nilUsage.CacheHitRatio() != 0 || nilUsage.String() != "" {
+		t.Fatalf("nil usage accessors")
+	}
+}
+
+func cachedUsage(prompt, cached, completion, reasoning int) *Usage {
+	return &Usage{
+		PromptTokens:            prompt,
+		CompletionTokens:        completion,
+		TotalTokens:             prompt + completion,
+		PromptTokensDetails:     &PromptTokensDetails{CachedTokens: cached},
+		CompletionTokensDetails: &CompletionTokensDetails{ReasoningTokens: reasoning},
+	}
+}
+
+func TestDoReACT_Offline_UsageAggregation(t *testing.T) {
+	first := fakeToolCalls(toolCall("call_1", "now", `{}`))
+	first.Usage = cachedUsage(1000, 0, 20, 10)
+	srv := newFakeServer(t,
+		first,
+		FakeResponse{Message: Message{Content: "done"}, Usage: cachedUsage(1100, 1000, 30, 0)},
+	)
+	tools, handlers := BuiltinTools()
+	rec := &eventRecorder{}
+	renderer := &bytes.Buffer{}
+
+	res, err := doReACT(context.Background(), srv.APIClient(), "qwen-plus", "sys", "time?", tools, handlers, 0, 4, WithObserver(rec), WithObserver(NewLiveRenderer(renderer)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := res.Usage()
+	if u.PromptTokens != 2100 || u.CachedTokens() != 1000 || u.ReasoningTokens() != 10 || u.TotalTokens != 2150 {
+		t.Fatalf("aggregate = %s", &u)
+	}
+	out := RenderReACTResult(res)
+	for _, want := range []string{"tokens=1130, cached=1000 (90.9%)", "usage (2 calls): prompt=2100 (cached=1000, 47.6%), completion=50 (reasoning=10), total=2150"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("render missing %q:\n%s", want, out)
+		}
+	}
+	if !strings.Contains(renderer.String(), "tokens=1100/30, cached=1000 (90.9%)") {
+		t.Fatalf("live:\n%s", renderer)
+	}
+}
+
+func TestSession_Offline_Usage(t *testing.T) {
+	srv := newFakeServer(t,
+		FakeResponse{Message: Message{Content: "a"}, Usage: cachedUsage(500, 0, 10, 0)},
+		FakeResponse{Message: Message{Content: "b"}, Usage: cachedUsage(520, 480, 10, 4)},
+	)
+	sess := NewSessionWithClient(srv.APIClient(), "qwen-plus")
+	for _, p := range []string{"one", "two"} {
+		if _, err := sess.Chat(context.Background(), p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	u := sess.Usage()
+	if u.PromptTokens != 1020 || u.CachedTokens() != 480 || u.ReasoningTokens() != 4 {
+		t.Fatalf("session usage = %s", &u)
+	}
+
+	var buf bytes.Buffer
+	if err := sess.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadSessionWithClient(&buf, srv.APIClient())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lu := loaded.Usage(); lu.String() != u.String() {
+		t.Fatalf("restored usage = %s, want %s", &lu, &u)
+	}
+	loaded.Reset(true)
+	if lu := loaded.Usage(); lu.TotalTokens != 0 {
+		t.Fatalf("usage after Reset = %s", &lu)
+	}
+}